@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Component is a part of the application with lifecycle hooks, e.g. a
+// database client, the tracer or the HTTP server. Every hook is optional.
+type Component struct {
+	Name string
+	// Start opens the component. It must not block; long running work
+	// belongs in Run. ctx stays valid until the application has stopped.
+	Start func(ctx context.Context) error
+	// Run blocks while the component serves, e.g. an HTTP server. It is
+	// started once every component has started and must return nil when
+	// stopped by Stop. A non-nil error shuts the application down.
+	Run func(ctx context.Context) error
+	// Stop releases the component. It must honor ctx, which carries the
+	// deadline shared by all components.
+	Stop func(ctx context.Context) error
+}
+
+// Config configures an App. Zero values fall back to the defaults
+// documented on each field.
+type Config struct {
+	Name        string        // Used in log lines.
+	StopTimeout time.Duration // Deadline shared by all Stop hooks (default 15s).
+	Signals     []os.Signal   // Signals that shut the application down (default SIGINT, SIGTERM).
+}
+
+// App starts its components in the order they were added, so a component
+// can use the ones added before it, and stops them in reverse order.
+type App struct {
+	cfg        Config
+	components []Component
+
+	mu      sync.Mutex
+	started int // number of components started, in order
+	stopped bool
+}
+
+// New creates an application without components.
+func New(cfg Config) *App {
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = 15 * time.Second
+	}
+	if len(cfg.Signals) == 0 {
+		cfg.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	return &App{cfg: cfg}
+}
+
+// Add registers components after the ones already added.
+// It must be called before Start.
+func (a *App) Add(components ...Component) {
+	a.components = append(a.components, components...)
+}
+
+// Start starts the components in order. When one fails, the components
+// already started are stopped in reverse order and all errors are returned.
+func (a *App) Start(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.started < len(a.components) {
+		c := a.components[a.started]
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				err = fmt.Errorf("app: start %s: %w", c.Name, err)
+				stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.cfg.StopTimeout)
+				defer cancel()
+				return errors.Join(err, a.stop(stopCtx))
+			}
+		}
+		a.started++
+	}
+	return nil
+}
+
+// Stop stops the started components in reverse order under the deadline
+// of ctx. Every component is stopped even when a previous one failed, and
+// all errors are returned. Calling Stop again does nothing.
+func (a *App) Stop(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stop(ctx)
+}
+
+func (a *App) stop(ctx context.Context) error {
+	if a.stopped {
+		return nil
+	}
+	a.stopped = true
+	var errs []error
+	for i := a.started - 1; i >= 0; i-- {
+		c := a.components[i]
+		if c.Stop == nil {
+			continue
+		}
+		if err := c.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("app: stop %s: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run starts the components, runs them until a shutdown signal is received,
+// ctx is done or a Run hook fails, and then stops them within
+// Config.StopTimeout. The returned error joins the start, run and stop errors.
+func (a *App) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, a.cfg.Signals...)
+	defer signal.Stop(sigCh)
+
+	if err := a.Start(ctx); err != nil {
+		return err
+	}
+	fmt.Println("[INFO] Run", a.cfg.Name)
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(a.components))
+	for _, c := range a.components {
+		if c.Run == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Run(ctx); err != nil {
+				errCh <- fmt.Errorf("app: run %s: %w", c.Name, err)
+			}
+		}()
+	}
+
+	var runErr error
+	select {
+	case sig := <-sigCh:
+		fmt.Println("[INFO] Shutdown", a.cfg.Name, "on", sig.String())
+	case <-ctx.Done():
+		fmt.Println("[INFO] Shutdown", a.cfg.Name)
+	case runErr = <-errCh:
+		fmt.Println("[ERROR] Shutdown", a.cfg.Name, "after failure:", runErr.Error())
+	}
+
+	stopCtx, cancelStop := context.WithTimeout(context.WithoutCancel(ctx), a.cfg.StopTimeout)
+	defer cancelStop()
+	stopErr := a.Stop(stopCtx)
+
+	// Wait for the Run hooks to return, but not past the deadline.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-stopCtx.Done():
+		stopErr = errors.Join(stopErr, fmt.Errorf("app: run hooks did not return within %s", a.cfg.StopTimeout))
+	}
+	for {
+		select {
+		case err := <-errCh:
+			runErr = errors.Join(runErr, err)
+		default:
+			return errors.Join(runErr, stopErr)
+		}
+	}
+}
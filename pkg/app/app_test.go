@@ -0,0 +1,102 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"research-apm/pkg/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the order in which the hooks are called.
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) component(name string, startErr, stopErr error) app.Component {
+	return app.Component{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			r.calls = append(r.calls, "start "+name)
+			return startErr
+		},
+		Stop: func(ctx context.Context) error {
+			r.calls = append(r.calls, "stop "+name)
+			return stopErr
+		},
+	}
+}
+
+// TestStartFailureStopsStarted verifies that a failed start releases the
+// components started before it, in reverse order.
+func TestStartFailureStopsStarted(t *testing.T) {
+	r := &recorder{}
+	a := app.New(app.Config{Name: "test"})
+	a.Add(
+		r.component("mongodb", nil, nil),
+		r.component("postgres", nil, errors.New("close failed")),
+		r.component("redis", errors.New("connection refused"), nil),
+		r.component("server", nil, nil),
+	)
+
+	err := a.Start(context.Background())
+	assert.ErrorContains(t, err, "app: start redis: connection refused")
+	assert.ErrorContains(t, err, "app: stop postgres: close failed")
+	assert.Equal(t, []string{"start mongodb", "start postgres", "start redis", "stop postgres", "stop mongodb"}, r.calls)
+
+	// Stop after a failed start does nothing.
+	assert.NoError(t, a.Stop(context.Background()))
+	assert.Len(t, r.calls, 5)
+}
+
+// TestRunFailureStopsAll verifies that a failing Run hook shuts the
+// application down and that Run returns its error.
+func TestRunFailureStopsAll(t *testing.T) {
+	r := &recorder{}
+	a := app.New(app.Config{Name: "test", StopTimeout: time.Second})
+	server := r.component("server", nil, nil)
+	stopped := make(chan struct{})
+	server.Run = func(ctx context.Context) error {
+		return errors.New("listener closed")
+	}
+	worker := r.component("worker", nil, nil)
+	worker.Run = func(ctx context.Context) error {
+		<-stopped
+		return nil
+	}
+	worker.Stop = func(ctx context.Context) error {
+		r.calls = append(r.calls, "stop worker")
+		close(stopped)
+		return nil
+	}
+	a.Add(r.component("tracer", nil, nil), server, worker)
+
+	err := a.Run(context.Background())
+	assert.ErrorContains(t, err, "app: run server: listener closed")
+	assert.Equal(t, []string{"start tracer", "start server", "start worker", "stop worker", "stop server", "stop tracer"}, r.calls)
+}
+
+// TestRunStopsOnContextDone verifies that the stop hooks share one deadline.
+func TestRunStopsOnContextDone(t *testing.T) {
+	a := app.New(app.Config{Name: "test", StopTimeout: 50 * time.Millisecond})
+	var deadlines []time.Time
+	stop := func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		deadlines = append(deadlines, deadline)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	a.Add(app.Component{Name: "a", Stop: stop}, app.Component{Name: "b", Stop: stop})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err := a.Run(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Len(t, deadlines, 2)
+	assert.Equal(t, deadlines[0], deadlines[1])
+}
@@ -0,0 +1,293 @@
+package breaker
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/tracer"
+)
+
+// ErrOpenState is the underlying error returned while the breaker rejects calls.
+var ErrOpenState = stderrors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	StateClosed   State = iota // calls pass through, outcomes are recorded
+	StateOpen                  // calls are rejected immediately
+	StateHalfOpen              // a limited number of probe calls pass through
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// Config defines when a breaker trips and how it recovers.
+// Zero values fall back to the defaults documented on each field.
+type Config struct {
+	Name             string                            // Dependency name used in logs and APM (required).
+	Window           time.Duration                     // Length of the rolling window (default 10s).
+	Buckets          int                               // Number of buckets in the window (default 10).
+	MinRequests      int                               // Minimum calls in the window before tripping (default 10).
+	FailureRatio     float64                           // Failure ratio that trips the breaker (default 0.5).
+	OpenTimeout      time.Duration                     // Time spent open before probing (default 30s).
+	HalfOpenMaxCalls int                               // Probe calls allowed while half-open (default 1).
+	IsFailure        func(err error) bool              // Decides if an error counts as a failure (default isFailure).
+	OnStateChange    func(name string, from, to State) // Optional hook called on every transition.
+}
+
+// Breaker is a circuit breaker with a rolling failure-ratio window.
+// It is safe for concurrent use.
+type Breaker struct {
+	cfg Config
+	now func() time.Time
+
+	mu         sync.Mutex
+	state      State
+	generation uint64 // incremented on every transition, used to drop stale results
+	openedAt   time.Time
+	inFlight   int // probe calls in flight while half-open
+	successes  int // successful probes while half-open
+	window     *window
+}
+
+// New creates a closed breaker from the given configuration.
+func New(cfg Config) *Breaker {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = 10
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 10
+	}
+	if cfg.FailureRatio <= 0 || cfg.FailureRatio > 1 {
+		cfg.FailureRatio = 0.5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isFailure
+	}
+	return &Breaker{
+		cfg:    cfg,
+		now:    time.Now,
+		state:  StateClosed,
+		window: newWindow(cfg.Window, cfg.Buckets),
+	}
+}
+
+// isFailure counts every error as a failure, except client errors (4xx AppError),
+// since they say nothing about the dependency health.
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if appErr, ok := err.(*errors.AppError); ok && appErr.Code.HttpStatus() < 500 {
+		return false
+	}
+	return true
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(context.Background(), b.now())
+	return b.state
+}
+
+// outcome is the result of a call as recorded by the breaker.
+type outcome int
+
+const (
+	outcomeSuccess  outcome = iota
+	outcomeFailure          // counted towards the failure ratio
+	outcomeCanceled         // the caller gave up: says nothing about the dependency
+)
+
+// Do runs fn if the breaker allows it and records its outcome.
+// While the breaker is open it returns immediately with a retryable
+// codes.Unavailable error wrapping ErrOpenState (see IsOpen).
+// A panic of fn is recorded as a failure and propagated.
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	generation, err := b.before(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b.after(ctx, generation, outcomeFailure)
+			panic(r)
+		}
+	}()
+	err = fn(ctx)
+	b.after(ctx, generation, b.outcome(ctx, err))
+	return err
+}
+
+func (b *Breaker) outcome(ctx context.Context, err error) outcome {
+	switch {
+	case err == nil:
+		return outcomeSuccess
+	case stderrors.Is(err, context.Canceled) || ctx.Err() == context.Canceled:
+		return outcomeCanceled
+	case b.cfg.IsFailure(err):
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// DoValue is like Breaker.Do but for operations that return a value.
+func DoValue[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := b.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+// before checks whether a call is allowed and returns the current generation.
+func (b *Breaker) before(ctx context.Context) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh(ctx, b.now())
+	switch b.state {
+	case StateOpen:
+		return b.generation, b.openError()
+	case StateHalfOpen:
+		if b.inFlight >= b.cfg.HalfOpenMaxCalls {
+			return b.generation, b.openError()
+		}
+		b.inFlight++
+	}
+	return b.generation, nil
+}
+
+// after records the outcome of a call started in the given generation.
+// A canceled call only gives back its probe slot.
+func (b *Breaker) after(ctx context.Context, generation uint64, result outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.refresh(ctx, now)
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case StateClosed:
+		if result == outcomeCanceled {
+			return
+		}
+		b.window.record(now, result == outcomeSuccess)
+		total, failures := b.window.counts(now)
+		if total >= b.cfg.MinRequests && float64(failures)/float64(total) >= b.cfg.FailureRatio {
+			b.setState(ctx, StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.inFlight--
+		switch result {
+		case outcomeCanceled:
+			return
+		case outcomeFailure:
+			b.setState(ctx, StateOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenMaxCalls {
+			b.setState(ctx, StateClosed, now)
+		}
+	}
+}
+
+// refresh moves an open breaker to half-open once the open timeout has passed.
+func (b *Breaker) refresh(ctx context.Context, now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.setState(ctx, StateHalfOpen, now)
+	}
+}
+
+// setState performs a transition and reports it.
+func (b *Breaker) setState(ctx context.Context, to State, now time.Time) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.generation++
+	b.inFlight = 0
+	b.successes = 0
+	switch to {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.window.reset()
+	}
+
+	level, prefix := "warning", "[WARN]"
+	if to == StateClosed {
+		level, prefix = "info", "[INFO]"
+	}
+	fmt.Println(prefix, "circuit breaker", b.cfg.Name, "changed from", from, "to", to)
+	tracer.CaptureEvent(ctx, level, fmt.Sprintf("circuit breaker %s changed from %s to %s", b.cfg.Name, from, to), map[string]any{
+		"breaker":     b.cfg.Name,
+		"breakerFrom": from.String(),
+		"breakerTo":   to.String(),
+	})
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(b.cfg.Name, from, to)
+	}
+}
+
+// openError builds the error returned while calls are rejected.
+func (b *Breaker) openError() error {
+	return errors.NewRetryable(errors.New(
+		codes.Unavailable,
+		fmt.Sprintf("%s is unavailable", b.cfg.Name),
+		fmt.Errorf("%s: %w", b.cfg.Name, ErrOpenState),
+	))
+}
+
+// IsOpen reports whether err was returned by an open breaker, e.g. to stop
+// a retry.Policy from retrying a call the breaker rejects anyway.
+func IsOpen(err error) bool {
+	for err != nil {
+		if stderrors.Is(err, ErrOpenState) {
+			return true
+		}
+		switch e := err.(type) {
+		case *errors.Retryable:
+			err = e.Errors
+		case *errors.AppError:
+			err = e.Errors
+		default:
+			return false
+		}
+	}
+	return false
+}
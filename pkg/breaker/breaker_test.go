@@ -0,0 +1,122 @@
+package breaker_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"research-apm/pkg/breaker"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errDown = fmt.Errorf("connection refused")
+
+func fail(ctx context.Context) error    { return errDown }
+func succeed(ctx context.Context) error { return nil }
+
+// TestBreakerTripsAndRecovers walks the breaker through closed -> open -> half-open -> closed.
+func TestBreakerTripsAndRecovers(t *testing.T) {
+	b := breaker.New(breaker.Config{
+		Name:        "mongo",
+		MinRequests: 4,
+		OpenTimeout: 20 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, b.Do(ctx, succeed))
+	assert.NoError(t, b.Do(ctx, succeed))
+	assert.Equal(t, errDown, b.Do(ctx, fail))
+	assert.Equal(t, breaker.StateClosed, b.State())
+
+	// 2 failures out of 4 calls reaches the default 0.5 ratio.
+	assert.Equal(t, errDown, b.Do(ctx, fail))
+	assert.Equal(t, breaker.StateOpen, b.State())
+
+	// While open, calls are rejected without running fn.
+	called := false
+	err := b.Do(ctx, func(ctx context.Context) error { called = true; return nil })
+	assert.False(t, called)
+	assert.True(t, errors.IsRetryable(err), "clients may retry later")
+	assert.True(t, breaker.IsOpen(err))
+	assert.False(t, breaker.IsOpen(errDown))
+	assert.Equal(t, codes.Unavailable, errors.Wrap(codes.Internal, "", err).Code)
+
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, breaker.StateHalfOpen, b.State())
+
+	assert.NoError(t, b.Do(ctx, succeed))
+	assert.Equal(t, breaker.StateClosed, b.State())
+}
+
+// TestBreakerHalfOpenFailure verifies that a failed probe reopens the breaker.
+func TestBreakerHalfOpenFailure(t *testing.T) {
+	b := breaker.New(breaker.Config{
+		Name:        "redis",
+		MinRequests: 1,
+		OpenTimeout: 10 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	b.Do(ctx, fail)
+	assert.Equal(t, breaker.StateOpen, b.State())
+
+	time.Sleep(15 * time.Millisecond)
+	b.Do(ctx, fail)
+	assert.Equal(t, breaker.StateOpen, b.State())
+}
+
+// TestBreakerIgnoresClientErrors verifies that 4xx AppErrors do not trip the breaker.
+func TestBreakerIgnoresClientErrors(t *testing.T) {
+	b := breaker.New(breaker.Config{Name: "mongo", MinRequests: 1})
+	notFound := errors.New(codes.DataNotFound, "data not found", fmt.Errorf("no documents"))
+
+	for range 5 {
+		b.Do(context.Background(), func(ctx context.Context) error { return notFound })
+	}
+	assert.Equal(t, breaker.StateClosed, b.State())
+}
+
+// TestBreakerHalfOpenPanic verifies that a panicking probe is counted as a
+// failure and gives back its slot.
+func TestBreakerHalfOpenPanic(t *testing.T) {
+	b := breaker.New(breaker.Config{
+		Name:        "mysql",
+		MinRequests: 1,
+		OpenTimeout: 10 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	b.Do(ctx, fail)
+	time.Sleep(15 * time.Millisecond)
+	assert.Panics(t, func() {
+		b.Do(ctx, func(ctx context.Context) error { panic("boom") })
+	})
+	assert.Equal(t, breaker.StateOpen, b.State())
+
+	time.Sleep(15 * time.Millisecond)
+	assert.NoError(t, b.Do(ctx, succeed), "the probe slot was given back")
+	assert.Equal(t, breaker.StateClosed, b.State())
+}
+
+// TestBreakerHalfOpenCanceled verifies that a canceled probe neither closes
+// nor reopens the breaker.
+func TestBreakerHalfOpenCanceled(t *testing.T) {
+	b := breaker.New(breaker.Config{
+		Name:        "postgres",
+		MinRequests: 1,
+		OpenTimeout: 10 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	b.Do(ctx, fail)
+	time.Sleep(15 * time.Millisecond)
+	assert.ErrorIs(t, b.Do(ctx, func(ctx context.Context) error { return context.Canceled }), context.Canceled)
+	assert.Equal(t, breaker.StateHalfOpen, b.State())
+
+	assert.Equal(t, errDown, b.Do(ctx, fail), "the probe slot was given back")
+	assert.Equal(t, breaker.StateOpen, b.State())
+}
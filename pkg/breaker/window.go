@@ -0,0 +1,65 @@
+package breaker
+
+import "time"
+
+// bucket holds the outcome counters of one slice of the rolling window.
+type bucket struct {
+	start    time.Time
+	success  int
+	failures int
+}
+
+// window is a rolling window of fixed-size buckets.
+// Buckets older than the window size are discarded when read or written.
+type window struct {
+	buckets []bucket
+	size    time.Duration // duration of a single bucket
+}
+
+func newWindow(length time.Duration, buckets int) *window {
+	return &window{
+		buckets: make([]bucket, buckets),
+		size:    length / time.Duration(buckets),
+	}
+}
+
+// current returns the bucket for now, resetting it if it belongs to an old slice.
+func (w *window) current(now time.Time) *bucket {
+	start := now.Truncate(w.size)
+	idx := int(start.UnixNano()/int64(w.size)) % len(w.buckets)
+	b := &w.buckets[idx]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	return b
+}
+
+// record adds an outcome to the current bucket.
+func (w *window) record(now time.Time, success bool) {
+	b := w.current(now)
+	if success {
+		b.success++
+	} else {
+		b.failures++
+	}
+}
+
+// counts returns the totals of all buckets within the window.
+func (w *window) counts(now time.Time) (total int, failures int) {
+	oldest := now.Truncate(w.size).Add(-w.size * time.Duration(len(w.buckets)-1))
+	for _, b := range w.buckets {
+		if b.start.Before(oldest) {
+			continue
+		}
+		total += b.success + b.failures
+		failures += b.failures
+	}
+	return total, failures
+}
+
+// reset clears all buckets.
+func (w *window) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
@@ -0,0 +1,126 @@
+package bulkhead
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+)
+
+// ErrFull is the underlying error returned when a call is rejected by the bulkhead.
+var ErrFull = stderrors.New("bulkhead is full")
+
+// Config defines the concurrency limits of a single dependency.
+type Config struct {
+	Name          string        // Dependency name used in error messages (required).
+	MaxConcurrent int           // Maximum calls in flight at once (default 10).
+	MaxQueue      int           // Maximum calls waiting for a slot, 0 disables queueing.
+	QueueTimeout  time.Duration // Maximum time a call may wait for a slot (default 1s).
+}
+
+// Bulkhead caps the number of concurrent calls to a dependency so that
+// one slow dependency cannot use up every goroutine of the service.
+// It is safe for concurrent use.
+type Bulkhead struct {
+	cfg    Config
+	slots  chan struct{}
+	queued atomic.Int64
+}
+
+// New creates a bulkhead from the given configuration.
+func New(cfg Config) *Bulkhead {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 10
+	}
+	if cfg.MaxQueue < 0 {
+		cfg.MaxQueue = 0
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = time.Second
+	}
+	return &Bulkhead{
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.MaxConcurrent),
+	}
+}
+
+// Name returns the dependency name of the bulkhead.
+func (b *Bulkhead) Name() string {
+	return b.cfg.Name
+}
+
+// InFlight returns the number of calls currently holding a slot.
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+// Queued returns the number of calls currently waiting for a slot.
+func (b *Bulkhead) Queued() int {
+	return int(b.queued.Load())
+}
+
+// Acquire takes a slot, waiting in the queue if needed.
+// It returns a retryable codes.Unavailable error when the queue is full or the
+// queue timeout expires. Every successful Acquire must be followed by Release.
+func (b *Bulkhead) Acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if b.queued.Add(1) > int64(b.cfg.MaxQueue) {
+		b.queued.Add(-1)
+		return b.rejectError("queue is full")
+	}
+	defer b.queued.Add(-1)
+
+	timer := time.NewTimer(b.cfg.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return b.rejectError("queue timeout")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire.
+func (b *Bulkhead) Release() {
+	<-b.slots
+}
+
+// Do runs fn inside the bulkhead.
+func (b *Bulkhead) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := b.Acquire(ctx); err != nil {
+		return err
+	}
+	defer b.Release()
+	return fn(ctx)
+}
+
+// DoValue is like Bulkhead.Do but for operations that return a value.
+func DoValue[T any](ctx context.Context, b *Bulkhead, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := b.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+// rejectError builds the error returned when a call is rejected.
+func (b *Bulkhead) rejectError(reason string) error {
+	return errors.NewRetryable(errors.New(
+		codes.Unavailable,
+		fmt.Sprintf("%s is busy", b.cfg.Name),
+		fmt.Errorf("%s: %w: %s", b.cfg.Name, ErrFull, reason),
+	))
+}
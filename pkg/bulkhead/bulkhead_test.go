@@ -0,0 +1,68 @@
+package bulkhead_test
+
+import (
+	"context"
+	stderrors "errors"
+	"research-apm/pkg/bulkhead"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rejected returns the AppError of a retryable rejection.
+func rejected(t *testing.T, err error) *errors.AppError {
+	t.Helper()
+	require.True(t, errors.IsRetryable(err), "rejections are retryable")
+	return errors.FromError(err.(*errors.Retryable).Errors)
+}
+
+func TestQueueFull(t *testing.T) {
+	ctx := context.Background()
+	b := bulkhead.New(bulkhead.Config{Name: "db", MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second})
+	require.NoError(t, b.Acquire(ctx))
+
+	queued := make(chan error)
+	go func() { queued <- b.Acquire(ctx) }()
+	require.Eventually(t, func() bool { return b.Queued() == 1 }, time.Second, time.Millisecond)
+
+	err := b.Acquire(ctx)
+	assert.ErrorIs(t, rejected(t, err).Errors, bulkhead.ErrFull)
+	assert.Equal(t, codes.Unavailable, rejected(t, err).Code)
+
+	b.Release()
+	require.NoError(t, <-queued, "the queued call gets the released slot")
+	assert.Equal(t, 1, b.InFlight())
+	b.Release()
+}
+
+func TestQueueTimeout(t *testing.T) {
+	ctx := context.Background()
+	b := bulkhead.New(bulkhead.Config{Name: "db", MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: 20 * time.Millisecond})
+	require.NoError(t, b.Acquire(ctx))
+	defer b.Release()
+
+	start := time.Now()
+	err := b.Acquire(ctx)
+	assert.ErrorIs(t, rejected(t, err).Errors, bulkhead.ErrFull)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, 0, b.Queued())
+}
+
+func TestContextCancelled(t *testing.T) {
+	b := bulkhead.New(bulkhead.Config{Name: "db", MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second})
+	require.NoError(t, b.Acquire(context.Background()))
+	defer b.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := b.Do(ctx, func(context.Context) error {
+		t.Fatal("fn must not run without a slot")
+		return nil
+	})
+	assert.True(t, stderrors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 0, b.Queued())
+}
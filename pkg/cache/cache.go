@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"research-apm/pkg/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// Cache is the cache-aside interface used by the repositories. It is
+// implemented by LRU, redisx.Cache and redisx.Tiered, and by Nop in tests.
+type Cache[T any] interface {
+	// GetOrLoad returns the cached value of key, or loads it with loader
+	// and caches it for ttl.
+	GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error)
+	// Delete removes keys, e.g. after the underlying data changed.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Nop is a Cache that caches nothing, e.g. to test a repository
+// without Redis.
+type Nop[T any] struct{}
+
+func (Nop[T]) GetOrLoad(ctx context.Context, _ string, _ time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	return loader(ctx)
+}
+
+func (Nop[T]) Delete(context.Context, ...string) error {
+	return nil
+}
+
+// Stats counts the hits and misses of a cache tier.
+type Stats struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewStats creates the stats of a cache tier and exposes them in
+// metrics.Default as cache_hits and cache_misses, labeled with
+// cache=name and tier. An empty name keeps them out of the registry.
+func NewStats(name, tier string) *Stats {
+	s := &Stats{}
+	if name == "" {
+		return s
+	}
+	labels := metrics.Labels{"cache": name, "tier": tier}
+	metrics.Default.CounterFunc("cache_hits", "Number of cache lookups that found the key.", labels, func() float64 {
+		return float64(s.hits.Load())
+	})
+	metrics.Default.CounterFunc("cache_misses", "Number of cache lookups that did not find the key.", labels, func() float64 {
+		return float64(s.misses.Load())
+	})
+	return s
+}
+
+// Hit records a lookup that found the key.
+func (s *Stats) Hit() {
+	s.hits.Add(1)
+}
+
+// Miss records a lookup that did not find the key.
+func (s *Stats) Miss() {
+	s.misses.Add(1)
+}
+
+// Hits returns the number of hits.
+func (s *Stats) Hits() int64 {
+	return s.hits.Load()
+}
+
+// Misses returns the number of misses.
+func (s *Stats) Misses() int64 {
+	return s.misses.Load()
+}
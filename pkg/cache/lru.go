@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// LRUConfig configures an LRU. Zero values fall back to the defaults
+// documented on each field.
+type LRUConfig struct {
+	Name string        // Metrics label; empty keeps the LRU out of metrics.Default.
+	Size int           // Maximum number of entries (default 1000).
+	TTL  time.Duration // Upper bound of every entry's TTL (default 1m).
+}
+
+type entry[T any] struct {
+	key     string
+	value   T
+	expires time.Time
+}
+
+// LRU is an in-process cache bounded by size and TTL. The least recently
+// used entry is evicted when it is full.
+//
+// Values are shared by every caller and must not be modified.
+type LRU[T any] struct {
+	cfg   LRUConfig
+	stats *Stats
+	group singleflight.Group
+
+	mu        sync.Mutex
+	items     map[string]*list.Element
+	order     *list.List // front is the most recently used
+	evictions atomic.Int64
+}
+
+// NewLRU creates an empty LRU. Its hits, misses and cache_evictions are
+// exposed in metrics.Default with tier="l1".
+func NewLRU[T any](cfg LRUConfig) *LRU[T] {
+	if cfg.Size <= 0 {
+		cfg.Size = 1000
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	c := &LRU[T]{
+		cfg:   cfg,
+		stats: NewStats(cfg.Name, "l1"),
+		items: map[string]*list.Element{},
+		order: list.New(),
+	}
+	if cfg.Name != "" {
+		metrics.Default.CounterFunc("cache_evictions", "Number of entries evicted to make room for new ones.", metrics.Labels{"cache": cfg.Name, "tier": "l1"}, func() float64 {
+			return float64(c.evictions.Load())
+		})
+		metrics.Default.GaugeFunc("cache_entries", "Number of entries in the cache.", metrics.Labels{"cache": cfg.Name, "tier": "l1"}, func() float64 {
+			return float64(c.Len())
+		})
+	}
+	return c
+}
+
+// Get returns the value of key if present and not expired.
+func (c *LRU[T]) Get(key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[T])
+		if time.Now().Before(e.expires) {
+			c.order.MoveToFront(el)
+			c.stats.Hit()
+			return e.value, true
+		}
+		c.remove(el)
+	}
+	c.stats.Miss()
+	var zero T
+	return zero, false
+}
+
+// Set stores value under key for ttl, capped at LRUConfig.TTL.
+func (c *LRU[T]) Set(key string, value T, ttl time.Duration) {
+	if ttl <= 0 || ttl > c.cfg.TTL {
+		ttl = c.cfg.TTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &entry[T]{key: key, value: value, expires: time.Now().Add(ttl)}
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(e)
+	for c.order.Len() > c.cfg.Size {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *LRU[T]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry[T]).key)
+}
+
+// Delete removes keys.
+func (c *LRU[T]) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// Purge removes every entry.
+func (c *LRU[T]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+}
+
+// Len returns the number of entries, including expired ones not yet removed.
+func (c *LRU[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the hit and miss counters.
+func (c *LRU[T]) Stats() *Stats {
+	return c.stats
+}
+
+// Evictions returns the number of entries evicted by size.
+func (c *LRU[T]) Evictions() int64 {
+	return c.evictions.Load()
+}
+
+// GetOrLoad returns the value of key, or loads it with loader and stores
+// it for ttl. Concurrent misses of a key share a single load. Errors are
+// not cached.
+func (c *LRU[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	// The flight runs without the caller's cancellation, so one canceled
+	// caller does not fail the others waiting for the same key.
+	ch := c.group.DoChan(key, func() (any, error) {
+		v, err := callLoader(context.WithoutCancel(ctx), loader)
+		if err == nil {
+			c.Set(key, v, ttl)
+		}
+		return v, err
+	})
+	select {
+	case res := <-ch:
+		value, _ := res.Val.(T)
+		return value, res.Err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// callLoader runs loader in the singleflight goroutine, which re-panics out
+// of reach of any recover and kills the process, so a loader panic is
+// returned as an INTERNAL_ERROR to every waiting caller instead.
+func callLoader[T any](ctx context.Context, loader func(ctx context.Context) (T, error)) (v T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero T
+			v, err = zero, errors.New(codes.Internal, "failed to load the data", fmt.Errorf("cache: loader panicked: %v", r))
+		}
+	}()
+	return loader(ctx)
+}
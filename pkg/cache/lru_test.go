@@ -0,0 +1,122 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"research-apm/pkg/cache"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLRUEvictionAndTTL verifies the size bound, the TTL and the counters.
+func TestLRUEvictionAndTTL(t *testing.T) {
+	c := cache.NewLRU[int](cache.LRUConfig{Size: 2, TTL: 50 * time.Millisecond})
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, 0)
+	c.Get("a") // b is now the least recently used
+	c.Set("c", 3, 0)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, int64(1), c.Evictions())
+
+	// The TTL is capped at LRUConfig.TTL.
+	time.Sleep(60 * time.Millisecond)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, int64(2), c.Stats().Hits())
+	assert.Equal(t, int64(2), c.Stats().Misses())
+}
+
+// TestLRUGetOrLoadCoalesces verifies that concurrent misses share one load.
+func TestLRUGetOrLoadCoalesces(t *testing.T) {
+	c := cache.NewLRU[string](cache.LRUConfig{})
+	var calls atomic.Int32
+	loader := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(context.Background(), "key", time.Minute, loader)
+			assert.NoError(t, err)
+			assert.Equal(t, "value", v)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+
+	assert.NoError(t, c.Delete(context.Background(), "key"))
+	c.GetOrLoad(context.Background(), "key", time.Minute, loader)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+// TestLRUGetOrLoadDetached verifies that a canceled caller does not cancel
+// the load shared with the other callers of the key.
+func TestLRUGetOrLoadDetached(t *testing.T) {
+	c := cache.NewLRU[int](cache.LRUConfig{Size: 10, TTL: time.Minute})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (int, error) {
+		close(started)
+		select {
+		case <-release:
+			return 1, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(ctx, "a", time.Minute, loader)
+		canceled <- err
+	}()
+	<-started
+	shared := make(chan int, 1)
+	go func() {
+		v, _ := c.GetOrLoad(context.Background(), "a", time.Minute, loader)
+		shared <- v
+	}()
+	cancel()
+	assert.ErrorIs(t, <-canceled, context.Canceled)
+
+	close(release)
+	assert.Equal(t, 1, <-shared)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+}
+
+// TestLRUGetOrLoadPanic verifies that a loader panic is returned as an
+// INTERNAL_ERROR instead of crashing the process, and is not cached.
+func TestLRUGetOrLoadPanic(t *testing.T) {
+	c := cache.NewLRU[int](cache.LRUConfig{Size: 10, TTL: time.Minute})
+
+	_, err := c.GetOrLoad(context.Background(), "a", time.Minute, func(ctx context.Context) (int, error) {
+		panic("nil map")
+	})
+	assert.Equal(t, codes.Internal, errors.FromError(err).Code)
+	assert.ErrorContains(t, errors.FromError(err).Errors, "nil map")
+
+	v, err := c.GetOrLoad(context.Background(), "a", time.Minute, func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
@@ -0,0 +1,196 @@
+package configx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Struct tags understood by Load:
+//
+//	env:"MONGO_DB_URL"   key looked up in the sources (fields without it are skipped)
+//	default:"10m"        value used when no source has the key
+//	required:"true"      the key must be set by a source or a default
+//	secret:"true"        masked by String
+//	sep:";"              separator of slice values (default ",")
+//	prefix:"MONGO_"      prefix of the keys of a nested struct
+const (
+	tagEnv      = "env"
+	tagDefault  = "default"
+	tagRequired = "required"
+	tagSecret   = "secret"
+	tagSep      = "sep"
+	tagPrefix   = "prefix"
+)
+
+// mask replaces secret values in String.
+const mask = "******"
+
+// Load fills the tagged fields of the struct pointed to by dst. Each key is
+// looked up in sources in order, the first source that has it wins, so
+// Load(&cfg, Env(), dotenv, yaml) lets the environment override the files.
+// A key set to an empty value counts as missing: the next source, then the
+// default, is used, so an empty KEY= line does not hide them.
+// Without sources the environment is used.
+//
+// All missing required keys and invalid values are reported together
+// in a single error.
+func Load(dst any, sources ...Source) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("configx: dst must be a pointer to a struct, got %T", dst)
+	}
+	if len(sources) == 0 {
+		sources = []Source{Env()}
+	}
+	var errs []error
+	walk(v.Elem(), "", func(field reflect.Value, sf reflect.StructField, key string) {
+		raw, ok := lookup(sources, key)
+		if !ok {
+			raw = sf.Tag.Get(tagDefault)
+		}
+		if raw == "" {
+			if sf.Tag.Get(tagRequired) == "true" {
+				errs = append(errs, fmt.Errorf("%s is required", key))
+			}
+			return
+		}
+		if err := set(field, raw, sf.Tag.Get(tagSep)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", key, err.Error()))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("configx: invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// String formats the tagged fields of cfg as KEY=value lines,
+// with secret values masked, so a loaded configuration can be logged.
+func String(cfg any) string {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Sprint(cfg)
+	}
+	var b strings.Builder
+	walk(v, "", func(field reflect.Value, sf reflect.StructField, key string) {
+		value := format(field)
+		if sf.Tag.Get(tagSecret) == "true" && value != "" {
+			value = mask
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, value)
+	})
+	return b.String()
+}
+
+// walk calls fn for every field with an env tag, descending into nested
+// structs with their prefix.
+func walk(v reflect.Value, prefix string, fn func(field reflect.Value, sf reflect.StructField, key string)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		field := v.Field(i)
+		if name, ok := sf.Tag.Lookup(tagEnv); ok {
+			fn(field, sf, prefix+name)
+			continue
+		}
+		if field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(time.Duration(0)) {
+			walk(field, prefix+sf.Tag.Get(tagPrefix), fn)
+		}
+	}
+}
+
+// lookup returns the first non-empty value of key in sources.
+func lookup(sources []Source, key string) (string, bool) {
+	for _, s := range sources {
+		if v, ok := s.Lookup(key); ok && v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// set parses raw into field.
+func set(field reflect.Value, raw string, sep string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", raw)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if sep == "" {
+			sep = ","
+		}
+		parts := strings.Split(raw, sep)
+		slice := reflect.MakeSlice(field.Type(), 0, len(parts))
+		for _, p := range parts {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := set(elem, p, sep); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		field.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// format is the inverse of set, used by String.
+func format(field reflect.Value) string {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(field.Int()).String()
+	}
+	if field.Kind() == reflect.Slice {
+		parts := make([]string, field.Len())
+		for i := range parts {
+			parts[i] = format(field.Index(i))
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(field.Interface())
+}
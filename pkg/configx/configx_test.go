@@ -0,0 +1,76 @@
+package configx_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"research-apm/pkg/configx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	Name     string        `env:"SERVICE_NAME" required:"true"`
+	Addr     string        `env:"HTTP_ADDR" default:":8080"`
+	Timeout  time.Duration `env:"TIMEOUT" default:"2s"`
+	Hosts    []string      `env:"ELASTIC_HOST"`
+	Password string        `env:"DB_PASS" secret:"true"`
+	Mongo    struct {
+		URL     string `env:"URL" required:"true" secret:"true"`
+		MaxPool uint64 `env:"MAX_POOL" default:"5"`
+	} `prefix:"MONGO_"`
+}
+
+// TestLoad verifies defaults, parsing, source precedence and masking.
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("# local\nSERVICE_NAME=from-dotenv\nexport DB_PASS=\"s3cret\"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("SERVICE_NAME: from-yaml\nMONGO_URL: mongodb://localhost\nELASTIC_HOST: [http://es-1:9200, http://es-2:9200]\n"), 0644)
+
+	dotenv, err := configx.DotEnv(filepath.Join(dir, ".env"))
+	assert.NoError(t, err)
+	yaml, err := configx.YAML(filepath.Join(dir, "config.yaml"))
+	assert.NoError(t, err)
+
+	var cfg testConfig
+	err = configx.Load(&cfg, configx.Map{"TIMEOUT": "1m"}, dotenv, yaml)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-dotenv", cfg.Name)
+	assert.Equal(t, ":8080", cfg.Addr)
+	assert.Equal(t, time.Minute, cfg.Timeout)
+	assert.Equal(t, []string{"http://es-1:9200", "http://es-2:9200"}, cfg.Hosts)
+	assert.Equal(t, "mongodb://localhost", cfg.Mongo.URL)
+	assert.Equal(t, uint64(5), cfg.Mongo.MaxPool)
+
+	out := configx.String(cfg)
+	assert.Contains(t, out, "DB_PASS=******\n")
+	assert.Contains(t, out, "MONGO_URL=******\n")
+	assert.NotContains(t, out, "s3cret")
+}
+
+// TestLoadEmptyValues verifies that an empty value falls through to the
+// next source, then to the default, and does not satisfy required.
+func TestLoadEmptyValues(t *testing.T) {
+	var cfg testConfig
+	err := configx.Load(&cfg,
+		configx.Map{"SERVICE_NAME": "", "HTTP_ADDR": "", "TIMEOUT": "", "MONGO_URL": ""},
+		configx.Map{"SERVICE_NAME": "from-second", "TIMEOUT": "1m"},
+	)
+	assert.ErrorContains(t, err, "MONGO_URL is required")
+	assert.NotContains(t, err.Error(), "SERVICE_NAME")
+	assert.Equal(t, "from-second", cfg.Name)
+	assert.Equal(t, ":8080", cfg.Addr)
+	assert.Equal(t, time.Minute, cfg.Timeout)
+}
+
+// TestLoadAggregatesErrors verifies that every problem is reported at once.
+func TestLoadAggregatesErrors(t *testing.T) {
+	var cfg testConfig
+	err := configx.Load(&cfg, configx.Map{"TIMEOUT": "soon", "MONGO_MAX_POOL": "-1"})
+	assert.ErrorContains(t, err, "SERVICE_NAME is required")
+	assert.ErrorContains(t, err, "MONGO_URL is required")
+	assert.ErrorContains(t, err, `TIMEOUT: invalid duration "soon"`)
+	assert.ErrorContains(t, err, `MONGO_MAX_POOL: invalid unsigned integer "-1"`)
+}
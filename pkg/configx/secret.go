@@ -0,0 +1,293 @@
+package configx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SecretProvider resolves secret references of one URL scheme.
+type SecretProvider interface {
+	// Scheme is the URL scheme handled by the provider, e.g. "file" or "vault".
+	Scheme() string
+	// Resolve returns the current value of ref.
+	Resolve(ctx context.Context, ref *url.URL) (string, error)
+}
+
+// FileProvider resolves file:///run/secrets/name references, e.g. Docker
+// or Kubernetes secrets mounted as files. A trailing newline is trimmed.
+type FileProvider struct{}
+
+func (FileProvider) Scheme() string {
+	return "file"
+}
+
+func (FileProvider) Resolve(_ context.Context, ref *url.URL) (string, error) {
+	data, err := os.ReadFile(ref.Host + ref.Path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// VaultProvider resolves vault://mount/path#key references against the
+// HTTP API of a Vault KV secrets engine (version 1 or 2), e.g.
+// vault://secret/data/research-apm#mongo_url reads GET /v1/secret/data/research-apm.
+type VaultProvider struct {
+	Address string       // Vault address, e.g. "http://127.0.0.1:8200".
+	Token   string       // Vault token sent as X-Vault-Token.
+	Client  *http.Client // Optional, defaults to a client with a 10s timeout.
+}
+
+func (p *VaultProvider) Scheme() string {
+	return "vault"
+}
+
+func (p *VaultProvider) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	if ref.Fragment == "" {
+		return "", fmt.Errorf("missing #key")
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	endpoint := strings.TrimRight(p.Address, "/") + "/v1/" + strings.TrimLeft(ref.Host+ref.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault responded %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode vault response: %s", err.Error())
+	}
+	data := body.Data
+	// KV version 2 nests the secret under data.data.
+	if nested, ok := data["data"].(map[string]any); ok {
+		data = nested
+	}
+	value, ok := data[ref.Fragment]
+	if !ok {
+		return "", fmt.Errorf("key %q not found", ref.Fragment)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// Secret holds the current value of a secret reference. It is updated in
+// place by Resolver.Watch, so holders always read the rotated value.
+type Secret struct {
+	key   string
+	ref   string
+	value atomic.Pointer[string]
+}
+
+// Value returns the current value of the secret.
+func (s *Secret) Value() string {
+	return *s.value.Load()
+}
+
+// String masks the value so a Secret can be printed safely.
+func (s *Secret) String() string {
+	return mask
+}
+
+// Resolver resolves secret references with its providers and keeps
+// track of them for rotation.
+type Resolver struct {
+	providers map[string]SecretProvider
+
+	mu      sync.Mutex
+	secrets map[string]*Secret // by config key
+}
+
+// NewResolver creates a resolver with the given providers.
+func NewResolver(providers ...SecretProvider) *Resolver {
+	r := &Resolver{providers: map[string]SecretProvider{}, secrets: map[string]*Secret{}}
+	for _, p := range providers {
+		r.providers[p.Scheme()] = p
+	}
+	return r
+}
+
+// Resolve returns the value of a secret reference, or value unchanged when
+// it is not a reference to one of the providers' schemes.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	ref, provider := r.parse(value)
+	if provider == nil {
+		return value, nil
+	}
+	return provider.Resolve(ctx, ref)
+}
+
+func (r *Resolver) parse(value string) (*url.URL, SecretProvider) {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return nil, nil
+	}
+	provider, ok := r.providers[scheme]
+	if !ok {
+		return nil, nil
+	}
+	ref, err := url.Parse(value)
+	if err != nil {
+		return nil, nil
+	}
+	return ref, provider
+}
+
+// Secret returns the secret loaded for key by ResolveSecrets,
+// or nil when the value of key was not a reference.
+func (r *Resolver) Secret(key string) *Secret {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.secrets[key]
+}
+
+// ResolveSecrets replaces every string field of dst loaded by Load whose
+// value is a secret reference, e.g. MONGO_DB_URL=file:///run/secrets/mongo_url,
+// with the resolved value. The elements of []string fields are resolved one
+// by one and kept under KEY[i], e.g. MESSAGE_DB_REPLICA_URLS[0].
+// All failures are reported in a single error.
+func ResolveSecrets(ctx context.Context, dst any, r *Resolver) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("configx: dst must be a pointer to a struct, got %T", dst)
+	}
+	var errs []error
+	walk(v.Elem(), "", func(field reflect.Value, _ reflect.StructField, key string) {
+		switch {
+		case field.Kind() == reflect.String:
+			if err := r.resolveField(ctx, field, key); err != nil {
+				errs = append(errs, err)
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+			for i := 0; i < field.Len(); i++ {
+				if err := r.resolveField(ctx, field.Index(i), fmt.Sprintf("%s[%d]", key, i)); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("configx: invalid secrets:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// resolveField replaces the secret reference in field, if any, with its
+// value and keeps the secret under key.
+func (r *Resolver) resolveField(ctx context.Context, field reflect.Value, key string) error {
+	ref, provider := r.parse(field.String())
+	if provider == nil {
+		return nil
+	}
+	value, err := provider.Resolve(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("%s: resolve %s: %s", key, ref.Redacted(), err.Error())
+	}
+	secret := &Secret{key: key, ref: field.String()}
+	secret.value.Store(&value)
+	r.mu.Lock()
+	r.secrets[key] = secret
+	r.mu.Unlock()
+	field.SetString(value)
+	return nil
+}
+
+// ErrRotated is returned by Resolver.Watch when a secret that is not
+// applied live has changed.
+var ErrRotated = errors.New("configx: secret rotated")
+
+// NewSecretResolver returns a resolver of file:// references, and of
+// vault://path#key references when vaultAddr is set. vaultToken may itself
+// be a file:// reference, e.g. to the token written by a Vault agent.
+func NewSecretResolver(ctx context.Context, vaultAddr, vaultToken string) (*Resolver, error) {
+	providers := []SecretProvider{FileProvider{}}
+	if vaultAddr != "" {
+		token, err := NewResolver(FileProvider{}).Resolve(ctx, vaultToken)
+		if err != nil {
+			return nil, fmt.Errorf("resolve VAULT_TOKEN: %s", err.Error())
+		}
+		providers = append(providers, &VaultProvider{Address: vaultAddr, Token: token})
+	}
+	return NewResolver(providers...), nil
+}
+
+// Watch re-resolves the secrets every interval until ctx is done and then
+// returns nil, so rotated credentials are picked up without a restart.
+// Failures keep the previous value and are logged. Secrets in live are read through Secret.Value by
+// their holders, so their rotation is only logged. Any other secret was copied
+// into a client, e.g. a database pool, when it was created: Watch returns an
+// error wrapping ErrRotated as soon as one changes, so the caller restarts
+// with the new value. An interval <= 0 disables the refresh.
+func (r *Resolver) Watch(ctx context.Context, interval time.Duration, live ...string) error {
+	if interval <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		var rotated []string
+		r.refresh(ctx, func(key string) {
+			if slices.Contains(live, key) {
+				fmt.Println("[INFO] secret rotated:", key)
+				return
+			}
+			rotated = append(rotated, key)
+		})
+		if len(rotated) > 0 {
+			return fmt.Errorf("%w: %s, restart to apply", ErrRotated, strings.Join(rotated, ", "))
+		}
+	}
+}
+
+// refresh re-resolves every secret once and calls onChange with the key
+// of each one whose value changed.
+func (r *Resolver) refresh(ctx context.Context, onChange func(key string)) {
+	r.mu.Lock()
+	secrets := make([]*Secret, 0, len(r.secrets))
+	for _, s := range r.secrets {
+		secrets = append(secrets, s)
+	}
+	r.mu.Unlock()
+
+	for _, s := range secrets {
+		value, err := r.Resolve(ctx, s.ref)
+		if err != nil {
+			fmt.Println("[ERROR] configx: refresh secret", s.key, err.Error())
+			continue
+		}
+		if value == s.Value() {
+			continue
+		}
+		s.value.Store(&value)
+		onChange(s.key)
+	}
+}
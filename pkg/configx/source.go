@@ -0,0 +1,119 @@
+package configx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source provides raw configuration values by key.
+type Source interface {
+	Lookup(key string) (string, bool)
+}
+
+// Map is a Source backed by a map, e.g. for tests.
+type Map map[string]string
+
+func (m Map) Lookup(key string) (string, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+// envSource reads the process environment.
+type envSource struct{}
+
+func (envSource) Lookup(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+// Env returns a Source reading the process environment.
+func Env() Source {
+	return envSource{}
+}
+
+// DotEnv reads KEY=value lines from a .env file. Blank lines and lines
+// starting with # are ignored, an optional "export " prefix and matching
+// quotes around the value are stripped. A missing file or an empty path
+// yields an empty source.
+func DotEnv(path string) (Source, error) {
+	m := Map{}
+	if path == "" {
+		return m, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("configx: open %s: %s", path, err.Error())
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("configx: %s:%d: expected KEY=value", path, line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		m[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("configx: read %s: %s", path, err.Error())
+	}
+	return m, nil
+}
+
+// YAML reads a flat YAML mapping of keys to scalars or lists, using the same
+// keys as the env tags:
+//
+//	MONGO_DB_URL: mongodb://localhost:27017
+//	ELASTIC_HOST: [http://es-1:9200, http://es-2:9200]
+//
+// Lists are joined with ",". A missing file or an empty path yields an
+// empty source.
+func YAML(path string) (Source, error) {
+	m := Map{}
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("configx: read %s: %s", path, err.Error())
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("configx: parse %s: %s", path, err.Error())
+	}
+	for k, v := range raw {
+		switch v := v.(type) {
+		case nil:
+		case []any:
+			parts := make([]string, len(v))
+			for i, p := range v {
+				parts[i] = fmt.Sprint(p)
+			}
+			m[k] = strings.Join(parts, ",")
+		case map[string]any:
+			return nil, fmt.Errorf("configx: %s: nested mapping %q is not supported", path, k)
+		default:
+			m[k] = fmt.Sprint(v)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,36 @@
+package elasticx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"research-apm/pkg/health"
+
+	"github.com/elastic/go-elasticsearch/v9"
+)
+
+// HealthCheck returns a readiness check that reads the cluster health.
+// A red cluster is down; yellow is accepted, since a single node cluster
+// never allocates its replicas.
+func HealthCheck(client *elasticsearch.Client) health.CheckFunc {
+	return func(ctx context.Context) error {
+		res, err := client.Cluster.Health(client.Cluster.Health.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.IsError() {
+			return fmt.Errorf("cluster health: %s", res.Status())
+		}
+		var body struct {
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			return fmt.Errorf("cluster health: %s", err.Error())
+		}
+		if body.Status == "red" {
+			return fmt.Errorf("cluster status is red")
+		}
+		return nil
+	}
+}
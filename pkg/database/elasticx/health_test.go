@@ -0,0 +1,43 @@
+package elasticx_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"research-apm/pkg/database/elasticx"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHealthCheck verifies that only a red or unreachable cluster fails the check.
+func TestHealthCheck(t *testing.T) {
+	status := "green"
+	code := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/_cluster/health", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"cluster_name":"research","status":%q}`, status)
+	}))
+	defer server.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	assert.NoError(t, err)
+	check := elasticx.HealthCheck(client)
+
+	assert.NoError(t, check(context.Background()))
+	status = "yellow"
+	assert.NoError(t, check(context.Background()))
+	status = "red"
+	assert.ErrorContains(t, check(context.Background()), "red")
+	code = http.StatusUnauthorized
+	assert.ErrorContains(t, check(context.Background()), "401")
+
+	server.Close()
+	assert.Error(t, check(context.Background()))
+}
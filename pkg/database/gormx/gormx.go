@@ -1,10 +1,13 @@
 package gormx
 
 import (
+	"errors"
 	"fmt"
+	"research-apm/pkg/tracer"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 // PoolConfig defines the database connection pool settings.
@@ -21,17 +24,29 @@ type Config struct {
 	Dialector  gorm.Dialector
 	PoolConfig *PoolConfig  // Optional connection pool configuration.
 	GormConfig *gorm.Config // Optional GORM configuration (can be nil).
+
+	// Replicas receive the reads outside transactions; writes, locking
+	// reads and reads with WithPrimary go to Dialector. Optional.
+	Replicas             []Replica
+	ReplicaPolicy        string        // PolicyRoundRobin (default), PolicyWeighted or PolicyRandom.
+	ReplicaCheckInterval time.Duration // Interval of the replica health checks (default 10s).
 }
 
 // NewClient initializes and returns a new GORM DB client.
 //
-// By default, it uses the APM instrumented MySQL driver
-// so that all queries are automatically traced by Elastic APM.
+// With the Elastic backend, queries are traced by the APM instrumented
+// dialectors in dialector/apm. With the OpenTelemetry backend, the gorm
+// tracing plugin is registered instead (the APM dialectors are then no-ops).
 //
 // Steps performed:
 // 1. Open the database connection with GORM.
-// 2. Apply connection pool configuration if provided.
-// 3. Ping the database to ensure the connection is alive.
+// 2. Register the OpenTelemetry plugin if that backend is active.
+// 3. Apply connection pool configuration if provided.
+// 4. Ping the database to ensure the connection is alive.
+// 5. Register the replicas, if any, with their health checks.
+//
+// A replica that is down does not fail NewClient: its reads go to the
+// other healthy replicas, or to the primary when none is left.
 func NewClient(cfg Config) (*gorm.DB, error) {
 	if cfg.GormConfig == nil {
 		cfg.GormConfig = &gorm.Config{}
@@ -41,6 +56,12 @@ func NewClient(cfg Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	if tracer.CurrentBackend() == tracer.BackendOtel {
+		if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+			return nil, err
+		}
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
@@ -54,13 +75,20 @@ func NewClient(cfg Config) (*gorm.DB, error) {
 	}
 
 	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
 		return nil, err
 	}
 
+	if len(cfg.Replicas) > 0 {
+		if err := useReplicas(db, cfg); err != nil {
+			return nil, errors.Join(err, Disconnect(db))
+		}
+	}
+
 	return db, nil
 }
 
-// Disconnect closes the underlying sql.DB connection.
+// Disconnect closes the underlying sql.DB connection and the replicas.
 //
 // Should be called when the application is shutting down
 // to gracefully release all database resources.
@@ -69,14 +97,21 @@ func Disconnect(db *gorm.DB) error {
 		return nil
 	}
 
+	var replicaErr error
+	if set, ok := replicaSets.LoadAndDelete(db); ok {
+		if err := set.(*replicaSet).close(); err != nil {
+			replicaErr = fmt.Errorf("failed to close replica connections: %s", err.Error())
+		}
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
-		return fmt.Errorf("failed to get sql.DB from gorm.DB: %s", err.Error())
+		return errors.Join(replicaErr, fmt.Errorf("failed to get sql.DB from gorm.DB: %s", err.Error()))
 	}
 
 	if err := sqlDB.Close(); err != nil {
-		return fmt.Errorf("failed to close database connection: %s", err.Error())
+		return errors.Join(replicaErr, fmt.Errorf("failed to close database connection: %s", err.Error()))
 	}
 
-	return nil
+	return replicaErr
 }
@@ -0,0 +1,19 @@
+package gormx
+
+import (
+	"context"
+	"research-apm/pkg/health"
+
+	"gorm.io/gorm"
+)
+
+// HealthCheck returns a readiness check that pings the database.
+func HealthCheck(db *gorm.DB) health.CheckFunc {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
@@ -0,0 +1,47 @@
+package gormx
+
+import (
+	"research-apm/pkg/metrics"
+
+	"gorm.io/gorm"
+)
+
+// RegisterMetrics exposes the connection pool stats of db in metrics.Default,
+// labeled with db=name, and the health of its replicas.
+func RegisterMetrics(name string, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	labels := metrics.Labels{"db": name}
+	reg := metrics.Default
+	reg.GaugeFunc("db_pool_max_open_connections", "Maximum number of open connections to the database.", labels, func() float64 {
+		return float64(sqlDB.Stats().MaxOpenConnections)
+	})
+	reg.GaugeFunc("db_pool_open_connections", "Number of established connections, in use and idle.", labels, func() float64 {
+		return float64(sqlDB.Stats().OpenConnections)
+	})
+	reg.GaugeFunc("db_pool_in_use_connections", "Number of connections currently in use.", labels, func() float64 {
+		return float64(sqlDB.Stats().InUse)
+	})
+	reg.GaugeFunc("db_pool_idle_connections", "Number of idle connections.", labels, func() float64 {
+		return float64(sqlDB.Stats().Idle)
+	})
+	reg.CounterFunc("db_pool_wait", "Number of connections waited for.", labels, func() float64 {
+		return float64(sqlDB.Stats().WaitCount)
+	})
+	reg.CounterFunc("db_pool_wait_duration_seconds", "Time blocked waiting for a new connection.", labels, func() float64 {
+		return sqlDB.Stats().WaitDuration.Seconds()
+	})
+	if set, ok := replicaSets.Load(db); ok {
+		for _, r := range set.(*replicaSet).replicas {
+			reg.GaugeFunc("db_replica_up", "Whether the replica passed its last health check (1) or not (0).", metrics.Labels{"db": name, "replica": r.name}, func() float64 {
+				if r.healthy.Load() {
+					return 1
+				}
+				return 0
+			})
+		}
+	}
+	return nil
+}
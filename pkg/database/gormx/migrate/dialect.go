@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// dialect holds the SQL that differs between the databases.
+type dialect struct {
+	createTable string             // Format with the table name.
+	bind        func(n int) string // Placeholder of the n-th argument, from 1.
+	lock        func(ctx context.Context, conn *sql.Conn, key string, timeout time.Duration) error
+	unlock      func(ctx context.Context, conn *sql.Conn, key string) error
+}
+
+func questionMark(int) string { return "?" }
+
+// dialects by gorm.Dialector.Name. The locks are session locks held by
+// the connection that runs the migrations, so they are released when
+// the connection drops.
+var dialects = map[string]dialect{
+	"postgres": {
+		createTable: "CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)",
+		bind:        func(n int) string { return fmt.Sprintf("$%d", n) },
+		lock: func(ctx context.Context, conn *sql.Conn, key string, timeout time.Duration) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryKey(key))
+			return err
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn, key string) error {
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", advisoryKey(key))
+			return err
+		},
+	},
+	"mysql": {
+		createTable: "CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at DATETIME NOT NULL)",
+		bind:        questionMark,
+		lock: func(ctx context.Context, conn *sql.Conn, key string, timeout time.Duration) error {
+			var got sql.NullInt64
+			if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", key, int(timeout.Seconds())).Scan(&got); err != nil {
+				return err
+			}
+			if got.Int64 != 1 {
+				return fmt.Errorf("timeout after %s", timeout)
+			}
+			return nil
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn, key string) error {
+			_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", key)
+			return err
+		},
+	},
+	"sqlserver": {
+		createTable: "IF OBJECT_ID(N'%[1]s', N'U') IS NULL CREATE TABLE %[1]s (version BIGINT NOT NULL PRIMARY KEY, name NVARCHAR(255) NOT NULL, applied_at DATETIME2 NOT NULL)",
+		bind:        func(n int) string { return fmt.Sprintf("@p%d", n) },
+		lock: func(ctx context.Context, conn *sql.Conn, key string, timeout time.Duration) error {
+			var got int
+			err := conn.QueryRowContext(ctx, `DECLARE @result int;
+EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2;
+SELECT @result`, key, timeout.Milliseconds()).Scan(&got)
+			if err != nil {
+				return err
+			}
+			if got < 0 {
+				return fmt.Errorf("sp_getapplock returned %d", got)
+			}
+			return nil
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn, key string) error {
+			_, err := conn.ExecContext(ctx, "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", key)
+			return err
+		},
+	},
+	// SQLite has no session locks; concurrent writers are serialized by
+	// the database lock and a version can only be recorded once.
+	"sqlite": {
+		createTable: "CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)",
+		bind:        questionMark,
+		lock:        func(context.Context, *sql.Conn, string, time.Duration) error { return nil },
+		unlock:      func(context.Context, *sql.Conn, string) error { return nil },
+	},
+}
+
+// advisoryKey maps key to the bigint key of pg_advisory_lock.
+func advisoryKey(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64())
+}
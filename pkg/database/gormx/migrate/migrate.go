@@ -0,0 +1,273 @@
+// Package migrate applies versioned SQL migrations to a gormx database.
+//
+// Migrations are pairs of files <version>_<name>.up.sql and
+// <version>_<name>.down.sql, usually embedded with embed.FS, in one
+// directory per dialect named after gorm.Dialector.Name: postgres, mysql,
+// sqlserver or sqlite. The applied versions are recorded in a version
+// table, and a session lock of the database keeps concurrent instances
+// from applying the same migrations.
+//
+// Every migration runs in a transaction with its version row. MySQL
+// commits DDL statements implicitly, so a failed MySQL migration may be
+// partially applied; it also needs multiStatements=true in the DSN for
+// files with more than one statement.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"research-apm/pkg/database/gormx"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Config defines the behavior of a Migrator.
+type Config struct {
+	Table       string        // Version table (default "schema_migrations").
+	DryRun      bool          // Only log and return the migrations that would run.
+	LockTimeout time.Duration // Wait for the lock held by other instances (default 1m).
+}
+
+// Status is a migration with its state in the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the migrations of one database.
+type Migrator struct {
+	db         *gorm.DB
+	dialect    dialect
+	cfg        Config
+	migrations []Migration
+}
+
+// New loads the migrations of the dialect of db from the directory of
+// fsys named after it, e.g. postgres/0001_create_messages.up.sql.
+func New(db *gorm.DB, fsys fs.FS, cfg Config) (*Migrator, error) {
+	name := db.Dialector.Name()
+	d, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("migrate: unsupported dialect %q", name)
+	}
+	sub, err := fs.Sub(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %s", err.Error())
+	}
+	migrations, err := Load(sub)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Table == "" {
+		cfg.Table = "schema_migrations"
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = time.Minute
+	}
+	return &Migrator{db: db, dialect: d, cfg: cfg, migrations: migrations}, nil
+}
+
+// Up applies the pending migrations in version order and returns the
+// ones applied. It stops at the first failure.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.run(ctx, "apply", func(applied map[uint64]time.Time) ([]Migration, error) {
+		var pending []Migration
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok {
+				pending = append(pending, mig)
+			}
+		}
+		return pending, nil
+	})
+}
+
+// Down reverts the last steps applied migrations, newest first, and
+// returns the ones reverted. Nothing runs when one of them has no down file.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("migrate: steps must be positive, got %d", steps)
+	}
+	byVersion := make(map[uint64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+	return m.run(ctx, "revert", func(applied map[uint64]time.Time) ([]Migration, error) {
+		versions := make([]uint64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		var revert []Migration
+		for _, v := range versions[:min(steps, len(versions))] {
+			mig, ok := byVersion[v]
+			if !ok {
+				return nil, fmt.Errorf("migrate: version %d is applied but has no migration file", v)
+			}
+			if mig.Down == "" {
+				return nil, fmt.Errorf("migrate: %s has no down file", mig)
+			}
+			revert = append(revert, mig)
+		}
+		return revert, nil
+	})
+}
+
+// Status returns every migration with whether it is applied. Applied
+// versions without a migration file are included with an empty name.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.appliedIfExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		statuses = append(statuses, Status{Migration: mig, Applied: ok, AppliedAt: at})
+		delete(applied, mig.Version)
+	}
+	for v, at := range applied {
+		statuses = append(statuses, Status{Migration: Migration{Version: v}, Applied: true, AppliedAt: at})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// run plans and runs the migrations under the migration lock, or only
+// logs them in dry-run mode.
+func (m *Migrator) run(ctx context.Context, verb string, plan func(applied map[uint64]time.Time) ([]Migration, error)) ([]Migration, error) {
+	if m.cfg.DryRun {
+		applied, err := m.appliedIfExists(ctx)
+		if err != nil {
+			return nil, err
+		}
+		todo, err := plan(applied)
+		if err != nil {
+			return nil, err
+		}
+		for _, mig := range todo {
+			fmt.Println("[INFO] migrate: dry run, would", verb, mig.String())
+		}
+		return todo, nil
+	}
+
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %s", err.Error())
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: get connection: %s", err.Error())
+	}
+	defer conn.Close()
+
+	key := "gormx_migrate:" + m.cfg.Table
+	if err := m.dialect.lock(ctx, conn, key, m.cfg.LockTimeout); err != nil {
+		return nil, fmt.Errorf("migrate: lock %s: %s", key, err.Error())
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := m.dialect.unlock(ctx, conn, key); err != nil {
+			fmt.Println("[ERROR] migrate: unlock", key, err.Error())
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(m.dialect.createTable, m.cfg.Table)); err != nil {
+		return nil, fmt.Errorf("migrate: create %s: %s", m.cfg.Table, err.Error())
+	}
+	// Read under the lock, after the migrations of other instances.
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	todo, err := plan(applied)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, len(todo))
+	for _, mig := range todo {
+		start := time.Now()
+		if err := m.exec(ctx, conn, mig, verb == "apply"); err != nil {
+			return done, fmt.Errorf("migrate: %s %s: %s", verb, mig, err.Error())
+		}
+		fmt.Println("[INFO] migrate:", verb, mig.String(), "in", time.Since(start).Round(time.Millisecond))
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// exec runs the up or down SQL of mig and records it in one transaction.
+func (m *Migrator) exec(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	body, record, args := mig.Down, "DELETE FROM %s WHERE version = %s", []any{int64(mig.Version)}
+	if up {
+		body, record = mig.Up, "INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)"
+		args = append(args, mig.Name, time.Now().UTC())
+	}
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return err
+	}
+	binds := make([]any, 0, len(args)+1)
+	binds = append(binds, m.cfg.Table)
+	for i := range args {
+		binds = append(binds, m.dialect.bind(i+1))
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(record, binds...), args...); err != nil {
+		return fmt.Errorf("record version: %s", err.Error())
+	}
+	return tx.Commit()
+}
+
+// applied reads the applied versions from the version table.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[uint64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %s", m.cfg.Table))
+	if err != nil {
+		return nil, fmt.Errorf("migrate: read %s: %s", m.cfg.Table, err.Error())
+	}
+	defer rows.Close()
+	applied := map[uint64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("migrate: read %s: %s", m.cfg.Table, err.Error())
+		}
+		applied[uint64(version)] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("migrate: read %s: %s", m.cfg.Table, err.Error())
+	}
+	return applied, nil
+}
+
+// appliedIfExists reads the applied versions without creating the
+// version table, which is missing before the first migration.
+func (m *Migrator) appliedIfExists(ctx context.Context) (map[uint64]time.Time, error) {
+	// Read from the primary: a replica may lag behind the last migration.
+	db := m.db.WithContext(gormx.WithPrimary(ctx))
+	if !db.Migrator().HasTable(m.cfg.Table) {
+		return map[uint64]time.Time{}, nil
+	}
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %s", err.Error())
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: get connection: %s", err.Error())
+	}
+	defer conn.Close()
+	return m.applied(ctx, conn)
+}
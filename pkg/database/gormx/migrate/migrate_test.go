@@ -0,0 +1,111 @@
+package migrate_test
+
+import (
+	"context"
+	"path/filepath"
+	"research-apm/pkg/database/gormx"
+	"research-apm/pkg/database/gormx/migrate"
+	"testing"
+	"testing/fstest"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var migrations = fstest.MapFS{
+	"sqlite/0001_create_messages.up.sql":   {Data: []byte("CREATE TABLE messages (id INTEGER PRIMARY KEY, message TEXT NOT NULL)")},
+	"sqlite/0001_create_messages.down.sql": {Data: []byte("DROP TABLE messages")},
+	"sqlite/0002_add_status.up.sql":        {Data: []byte("ALTER TABLE messages ADD COLUMN status INTEGER NOT NULL DEFAULT 0")},
+	"sqlite/0002_add_status.down.sql":      {Data: []byte("ALTER TABLE messages DROP COLUMN status")},
+	"postgres/0001_create_messages.up.sql": {Data: []byte("CREATE TABLE public.messages (id SERIAL PRIMARY KEY)")},
+}
+
+func newDB(t *testing.T) *gorm.DB {
+	db, err := gormx.NewClient(gormx.Config{Dialector: sqlite.Open(filepath.Join(t.TempDir(), "test.db"))})
+	require.NoError(t, err)
+	t.Cleanup(func() { gormx.Disconnect(db) })
+	return db
+}
+
+func versions(migrations []migrate.Migration) []uint64 {
+	out := make([]uint64, len(migrations))
+	for i, m := range migrations {
+		out[i] = m.Version
+	}
+	return out
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	m, err := migrate.New(db, migrations, migrate.Config{})
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, versions(applied))
+	assert.True(t, db.Migrator().HasColumn("messages", "status"))
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "applied migrations run once")
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	assert.True(t, status[1].Applied)
+	assert.Equal(t, "add_status", status[1].Name)
+	assert.False(t, status[1].AppliedAt.IsZero())
+
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2}, versions(reverted))
+	assert.False(t, db.Migrator().HasColumn("messages", "status"))
+	assert.True(t, db.Migrator().HasTable("messages"))
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	m, err := migrate.New(db, migrations, migrate.Config{DryRun: true})
+	require.NoError(t, err)
+
+	pending, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, versions(pending))
+	assert.False(t, db.Migrator().HasTable("messages"))
+	assert.False(t, db.Migrator().HasTable("schema_migrations"), "dry run creates nothing")
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	fsys := fstest.MapFS{
+		"sqlite/1_create.up.sql": {Data: []byte("CREATE TABLE a (id INTEGER)")},
+		"sqlite/2_broken.up.sql": {Data: []byte("CREATE TABLE b (id INTEGER); INSERT INTO missing VALUES (1)")},
+	}
+	m, err := migrate.New(db, fsys, migrate.Config{})
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	assert.ErrorContains(t, err, "apply 2_broken")
+	assert.Equal(t, []uint64{1}, versions(applied))
+	assert.False(t, db.Migrator().HasTable("b"), "the failed migration is rolled back")
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status[1].Applied)
+}
+
+func TestLoad(t *testing.T) {
+	_, err := migrate.Load(fstest.MapFS{"1_a.down.sql": {Data: []byte("DROP TABLE a")}})
+	assert.ErrorContains(t, err, "no up file")
+
+	_, err = migrate.Load(fstest.MapFS{"a.up.sql": {Data: []byte("SELECT 1")}})
+	assert.ErrorContains(t, err, "positive number")
+
+	_, err = migrate.Load(fstest.MapFS{"1_a.sql": {Data: []byte("SELECT 1")}})
+	assert.ErrorContains(t, err, ".up.sql")
+}
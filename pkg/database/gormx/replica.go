@@ -0,0 +1,231 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Replica routing policies.
+const (
+	PolicyRoundRobin = "round_robin"
+	PolicyWeighted   = "weighted"
+	PolicyRandom     = "random"
+)
+
+// Replica is a read replica of the primary database.
+type Replica struct {
+	Name      string         // Used in logs and metrics, e.g. "replica-1".
+	Dialector gorm.Dialector // Use the APM dialectors so replica queries are traced too.
+	Weight    int            // Relative share of the reads with PolicyWeighted (default 1).
+}
+
+type primaryKey struct{}
+
+// WithPrimary forces the reads made with ctx to the primary, e.g. to read
+// your own writes right after a write, before the replicas caught up.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryKey{}).(bool)
+	return forced
+}
+
+// replicaSet routes the reads of a primary to its healthy replicas.
+type replicaSet struct {
+	primary  gorm.ConnPool
+	replicas []*replicaState
+	byPool   map[gorm.ConnPool]*replicaState
+	policy   string
+	next     atomic.Uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type replicaState struct {
+	name    string
+	weight  int
+	pool    gorm.ConnPool
+	healthy atomic.Bool
+}
+
+// replicaSets holds the replicas of every client, for Disconnect and metrics.
+var replicaSets sync.Map // *gorm.DB -> *replicaSet
+
+// useReplicas registers dbresolver with the replicas of cfg and the
+// routing callbacks that add health based fallback and WithPrimary.
+func useReplicas(db *gorm.DB, cfg Config) error {
+	dialectors := make([]gorm.Dialector, len(cfg.Replicas))
+	for i, r := range cfg.Replicas {
+		dialectors[i] = r.Dialector
+	}
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: dialectors})
+	if pool := cfg.PoolConfig; pool != nil {
+		resolver.SetMaxOpenConns(pool.MaxOpenCon).
+			SetMaxIdleConns(pool.MaxIdleCon).
+			SetConnMaxLifetime(pool.MaxLifetimeCon).
+			SetConnMaxIdleTime(pool.MaxIdleTimeCon)
+	}
+
+	// A replica that is down at startup must not fail the client;
+	// the health checks keep it out of the rotation instead.
+	ping := db.Config.DisableAutomaticPing
+	db.Config.DisableAutomaticPing = true
+	err := db.Use(resolver)
+	db.Config.DisableAutomaticPing = ping
+	if err != nil {
+		return fmt.Errorf("failed to open replicas: %s", err.Error())
+	}
+
+	// Call visits the primary first, then the replicas in order.
+	var pools []gorm.ConnPool
+	resolver.Call(func(pool gorm.ConnPool) error {
+		pools = append(pools, pool)
+		return nil
+	})
+	if len(pools) != len(cfg.Replicas)+1 {
+		return fmt.Errorf("failed to open replicas: got %d connection pools", len(pools))
+	}
+
+	set := &replicaSet{
+		primary: pools[0],
+		byPool:  map[gorm.ConnPool]*replicaState{},
+		policy:  cfg.ReplicaPolicy,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for i, r := range cfg.Replicas {
+		state := &replicaState{name: r.Name, weight: max(r.Weight, 1), pool: pools[i+1]}
+		if state.name == "" {
+			state.name = fmt.Sprintf("replica-%d", i+1)
+		}
+		set.replicas = append(set.replicas, state)
+		set.byPool[state.pool] = state
+	}
+	set.check()
+
+	// route must run between the pick of dbresolver and the execution
+	// of the statement.
+	for _, cb := range []interface {
+		Register(name string, fn func(*gorm.DB)) error
+	}{
+		db.Callback().Query().After("gorm:db_resolver").Before("gorm:query"),
+		db.Callback().Row().After("gorm:db_resolver").Before("gorm:row"),
+		db.Callback().Raw().After("gorm:db_resolver").Before("gorm:raw"),
+	} {
+		if err := cb.Register("gormx:replica", set.route); err != nil {
+			return err
+		}
+	}
+
+	interval := cfg.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	go set.watch(interval)
+	replicaSets.Store(db, set)
+	return nil
+}
+
+// route runs after dbresolver picked a connection pool and replaces a
+// replica by the primary when forced by WithPrimary, or by a healthy
+// replica of the policy, or by the primary when none is healthy.
+func (s *replicaSet) route(db *gorm.DB) {
+	pool := db.Statement.ConnPool
+	if prepared, ok := pool.(*gorm.PreparedStmtDB); ok {
+		pool = prepared.ConnPool
+	}
+	if _, ok := s.byPool[pool]; !ok {
+		return // primary or transaction
+	}
+	if usePrimary(db.Statement.Context) {
+		db.Statement.ConnPool = s.primary
+		return
+	}
+	db.Statement.ConnPool = s.pick()
+}
+
+func (s *replicaSet) pick() gorm.ConnPool {
+	healthy := make([]*replicaState, 0, len(s.replicas))
+	total := 0
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+			total += r.weight
+		}
+	}
+	if len(healthy) == 0 {
+		return s.primary
+	}
+	switch s.policy {
+	case PolicyRandom:
+		return healthy[rand.IntN(len(healthy))].pool
+	case PolicyWeighted:
+		n := int(s.next.Add(1) % uint64(total))
+		for _, r := range healthy {
+			if n < r.weight {
+				return r.pool
+			}
+			n -= r.weight
+		}
+	}
+	return healthy[s.next.Add(1)%uint64(len(healthy))].pool
+}
+
+// check pings every replica and updates its health.
+func (s *replicaSet) check() {
+	for _, r := range s.replicas {
+		err := errors.New("connection pool cannot be pinged")
+		if p, ok := r.pool.(interface{ PingContext(context.Context) error }); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			err = p.PingContext(ctx)
+			cancel()
+		}
+		if was := r.healthy.Swap(err == nil); was != (err == nil) {
+			if err != nil {
+				fmt.Println("[WARN] gormx: replica", r.name, "is down, reads fall back:", err.Error())
+			} else {
+				fmt.Println("[INFO] gormx: replica", r.name, "is up")
+			}
+		}
+	}
+}
+
+func (s *replicaSet) watch(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+// close stops the health checks and closes the replica connections.
+func (s *replicaSet) close() error {
+	close(s.stop)
+	<-s.done
+	var errs []error
+	for _, r := range s.replicas {
+		if c, ok := r.pool.(interface{ Close() error }); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("replica %s: %s", r.name, err.Error()))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package gormx_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"research-apm/pkg/database/gormx"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newSQLiteFile creates a SQLite database holding a single item named name.
+func newSQLiteFile(t *testing.T, name string) string {
+	path := filepath.Join(t.TempDir(), name+".db")
+	db, err := gorm.Open(sqlite.Open(path))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&item{}))
+	require.NoError(t, db.Create(&item{Name: name}).Error)
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+	return path
+}
+
+// newReplicatedDB creates a client of a primary with the given replicas,
+// each a separate SQLite file, so the item read tells which one served it.
+func newReplicatedDB(t *testing.T, replicas ...gormx.Replica) *gorm.DB {
+	db, err := gormx.NewClient(gormx.Config{
+		Dialector: sqlite.Open(newSQLiteFile(t, "primary")),
+		Replicas:  replicas,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { gormx.Disconnect(db) })
+	return db
+}
+
+// served returns the name of the item read with ctx by a query, a row
+// scan and a raw select.
+func served(t *testing.T, ctx context.Context, db *gorm.DB) []string {
+	var query, row, raw string
+	require.NoError(t, db.WithContext(ctx).Model(&item{}).Select("name").Limit(1).Scan(&query).Error)
+	require.NoError(t, db.WithContext(ctx).Model(&item{}).Select("name").Row().Scan(&row))
+	require.NoError(t, db.WithContext(ctx).Raw("SELECT name FROM items LIMIT 1").Scan(&raw).Error)
+	return []string{query, row, raw}
+}
+
+// downPool is the connection pool of a replica whose health check fails.
+type downPool struct {
+	gorm.ConnPool
+}
+
+func (downPool) PingContext(context.Context) error {
+	return errors.New("replica is down")
+}
+
+// downReplica is a replica holding a "down" item whose health check fails.
+func downReplica(t *testing.T) gormx.Replica {
+	sqlDB, err := sql.Open("sqlite", newSQLiteFile(t, "down"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return gormx.Replica{Name: "down", Dialector: &sqlite.Dialector{Conn: downPool{sqlDB}}}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	db := newReplicatedDB(t, gormx.Replica{Name: "replica", Dialector: sqlite.Open(newSQLiteFile(t, "replica"))})
+	ctx := context.Background()
+
+	assert.Equal(t, []string{"replica", "replica", "replica"}, served(t, ctx, db))
+	assert.Equal(t, []string{"primary", "primary", "primary"}, served(t, gormx.WithPrimary(ctx), db))
+
+	// Writes and transactions use the primary.
+	require.NoError(t, db.WithContext(ctx).Create(&item{Name: "written"}).Error)
+	assert.Equal(t, []string{"primary", "written"}, names(t, db.WithContext(gormx.WithPrimary(ctx))))
+	err := gormx.WithTx(ctx, db, func(ctx context.Context) error {
+		assert.Equal(t, []string{"primary", "written"}, names(t, gormx.FromContext(ctx, db)))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestReplicaUnhealthy(t *testing.T) {
+	ctx := context.Background()
+
+	// The reads skip the unhealthy replica.
+	db := newReplicatedDB(t, downReplica(t), gormx.Replica{Name: "replica", Dialector: sqlite.Open(newSQLiteFile(t, "replica"))})
+	for range 3 {
+		assert.Equal(t, []string{"replica", "replica", "replica"}, served(t, ctx, db))
+	}
+
+	// Without a healthy replica the reads fall back to the primary.
+	db = newReplicatedDB(t, downReplica(t))
+	assert.Equal(t, []string{"primary", "primary", "primary"}, served(t, ctx, db))
+}
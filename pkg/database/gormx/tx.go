@@ -0,0 +1,107 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	appErr "research-apm/pkg/errors"
+	"research-apm/pkg/retry"
+	"research-apm/pkg/tracer"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// TxConfig defines how WithTxConfig runs a transaction.
+type TxConfig struct {
+	// Policy retries the whole transaction on deadlocks, serialization
+	// failures and errors.Retryable. Zero fields use the retry defaults.
+	Policy  retry.Policy
+	Options *sql.TxOptions // Optional isolation level and read-only flag.
+}
+
+// txKey carries the transaction of a database, so that transactions on
+// several databases can share a context.
+type txKey struct{ db *gorm.DB }
+
+// WithTx runs fn in a transaction of db with the default TxConfig.
+func WithTx(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) error {
+	return WithTxConfig(ctx, db, TxConfig{}, fn)
+}
+
+// WithTxConfig runs fn in a transaction of db carried by the context
+// passed to fn; repositories get it with FromContext. The transaction is
+// committed when fn returns nil and rolled back when fn returns an error
+// or panics.
+//
+// A call inside the transaction of the same db creates a savepoint
+// instead, rolled back alone when its fn fails. The outermost call is
+// traced as one span and retried as a whole on transient failures, so fn
+// may run more than once and must not have side effects outside db.
+func WithTxConfig(ctx context.Context, db *gorm.DB, cfg TxConfig, fn func(ctx context.Context) error) error {
+	key := txKey{db}
+	if tx, ok := ctx.Value(key).(*gorm.DB); ok {
+		return tx.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, key, tx))
+		})
+	}
+
+	if cfg.Policy.Name == "" {
+		cfg.Policy.Name = "gormx.tx"
+	}
+	return tracer.Do(ctx, "gormx.transaction", func(ctx context.Context) error {
+		return retry.Do(ctx, cfg.Policy, func(ctx context.Context) error {
+			err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return fn(context.WithValue(ctx, key, tx))
+			}, cfg.Options)
+			if isTransient(err) {
+				return appErr.NewRetryable(err)
+			}
+			return err
+		})
+	})
+}
+
+// FromContext returns the transaction of db carried by ctx, or db when
+// there is none, bound to ctx.
+func FromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{db}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// isTransient reports whether err aborted the transaction but a new
+// attempt may succeed: a deadlock, a serialization failure, a lock wait
+// timeout or a broken connection.
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	// PostgreSQL (pgx v4 and v5): serialization_failure, deadlock_detected.
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		switch pgErr.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	// MySQL: ER_LOCK_DEADLOCK, ER_LOCK_WAIT_TIMEOUT.
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1213 || myErr.Number == 1205
+	}
+	// SQL Server: deadlock victim, lock request timeout.
+	var msErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &msErr) {
+		switch msErr.SQLErrorNumber() {
+		case 1205, 1222:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package gormx_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"research-apm/pkg/database/gormx"
+	appErr "research-apm/pkg/errors"
+	"research-apm/pkg/retry"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID   int
+	Name string
+}
+
+func newDB(t *testing.T) *gorm.DB {
+	db, err := gormx.NewClient(gormx.Config{Dialector: sqlite.Open(filepath.Join(t.TempDir(), "test.db"))})
+	require.NoError(t, err)
+	t.Cleanup(func() { gormx.Disconnect(db) })
+	require.NoError(t, db.AutoMigrate(&item{}))
+	return db
+}
+
+func names(t *testing.T, db *gorm.DB) []string {
+	var out []string
+	require.NoError(t, db.Model(&item{}).Order("id").Pluck("name", &out).Error)
+	return out
+}
+
+func insert(ctx context.Context, db *gorm.DB, name string) error {
+	return gormx.FromContext(ctx, db).Create(&item{Name: name}).Error
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	err := gormx.WithTx(ctx, db, func(ctx context.Context) error {
+		return insert(ctx, db, "committed")
+	})
+	require.NoError(t, err)
+
+	failure := errors.New("failure")
+	err = gormx.WithTx(ctx, db, func(ctx context.Context) error {
+		require.NoError(t, insert(ctx, db, "rolled back"))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"committed"}, names(t, db))
+}
+
+func TestWithTxNestedSavepoint(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	err := gormx.WithTx(ctx, db, func(ctx context.Context) error {
+		require.NoError(t, insert(ctx, db, "outer"))
+		inner := gormx.WithTx(ctx, db, func(ctx context.Context) error {
+			require.NoError(t, insert(ctx, db, "inner"))
+			return errors.New("inner failure")
+		})
+		assert.Error(t, inner)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer"}, names(t, db), "only the savepoint is rolled back")
+}
+
+func TestWithTxRetry(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	attempts := 0
+	err := gormx.WithTxConfig(ctx, db, gormx.TxConfig{Policy: retry.Policy{InitialBackoff: time.Millisecond}}, func(ctx context.Context) error {
+		attempts++
+		require.NoError(t, insert(ctx, db, "attempt"))
+		if attempts == 1 {
+			return appErr.NewRetryable(errors.New("deadlock"))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, []string{"attempt"}, names(t, db), "the failed attempt is rolled back")
+}
@@ -3,11 +3,46 @@ package mongox
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
 	appErr "research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
+// MongoDB error labels attached by the server (or driver) to transient failures.
+// Source: https://www.mongodb.com/docs/manual/core/retryable-writes/#retryable-writes-and-errors
+const (
+	labelRetryableWrite       = "RetryableWriteError"
+	labelTransientTransaction = "TransientTransactionError"
+	labelUnknownCommitResult  = "UnknownTransactionCommitResult"
+)
+
+// codeDocumentValidationFailure is returned when a document does not
+// satisfy the collection's JSON schema validator.
+const codeDocumentValidationFailure = 121
+
+// List of MongoDB server error codes considered retryable.
+// Source: https://www.mongodb.com/docs/manual/core/retryable-writes/#retryable-errors
+var retryableCodes = map[int]bool{
+	6:     true, // HostUnreachable
+	7:     true, // HostNotFound
+	89:    true, // NetworkTimeout
+	91:    true, // ShutdownInProgress
+	189:   true, // PrimarySteppedDown
+	262:   true, // ExceededTimeLimit
+	9001:  true, // SocketException
+	10107: true, // NotMaster (deprecated, still relevant)
+	11600: true, // InterruptedAtShutdown
+	11602: true, // InterruptedDueToReplStateChange
+	13435: true, // NotPrimaryNoSecondaryOk
+	13436: true, // NotPrimaryOrSecondary
+}
+
+// dupKeyPattern extracts the "dup key: { ... }" part of an E11000 message.
+var dupKeyPattern = regexp.MustCompile(`dup key: (\{.*\})`)
+
 // isRetryable determines whether a MongoDB error is transient and safe to retry.
 func isRetryable(err error) bool {
 	if err == nil {
@@ -21,57 +56,135 @@ func isRetryable(err error) bool {
 		return true
 	}
 
-	// List of MongoDB server error codes considered retryable.
-	// Source: https://www.mongodb.com/docs/manual/core/retryable-writes/#retryable-errors
-	var retryableCodes = map[int]bool{
-		6:     true, // HostUnreachable
-		7:     true, // HostNotFound
-		89:    true, // NetworkTimeout
-		91:    true, // ShutdownInProgress
-		189:   true, // PrimarySteppedDown
-		262:   true, // ExceededTimeLimit
-		9001:  true, // SocketException
-		10107: true, // NotMaster (deprecated, still relevant)
-		11600: true, // InterruptedAtShutdown
-		11602: true, // InterruptedDueToReplStateChange
-		13435: true, // NotPrimaryNoSecondaryOk
-		13436: true, // NotPrimaryOrSecondary
-	}
-
-	// Check CommandError (typically from read/write commands like find, aggregate, etc.)
-	var cmdErr mongo.CommandError
-	if errors.As(err, &cmdErr) {
-		if retryableCodes[int(cmdErr.Code)] {
+	// Errors labelled by the server as retryable writes or transient
+	// transaction failures are safe to retry regardless of their code.
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) {
+		if labeled.HasErrorLabel(labelRetryableWrite) || labeled.HasErrorLabel(labelTransientTransaction) {
 			return true
 		}
 	}
 
-	// Check WriteException (from insert, update, delete operations)
-	var writeErr mongo.WriteException
-	if errors.As(err, &writeErr) {
-		// Retry if any individual write error has a retryable code
-		for _, we := range writeErr.WriteErrors {
-			if retryableCodes[we.Code] {
+	// Check any server error (CommandError, WriteException, BulkWriteException)
+	// including nested write errors and write concern errors.
+	var srvErr mongo.ServerError
+	if errors.As(err, &srvErr) {
+		for _, code := range srvErr.ErrorCodes() {
+			if retryableCodes[code] {
 				return true
 			}
 		}
-		// Retry if the write concern error is retryable
-		if wcErr := writeErr.WriteConcernError; wcErr != nil {
-			if retryableCodes[wcErr.Code] {
-				return true
-			}
+	}
+
+	return false
+}
+
+// IsTransientTransactionError reports whether err, or the driver error
+// wrapped by NewError, is labelled TransientTransactionError: the
+// transaction was aborted and may succeed when run again from the start.
+func IsTransientTransactionError(err error) bool {
+	return hasErrorLabel(err, labelTransientTransaction)
+}
+
+// IsUnknownCommitResult reports whether err, or the driver error wrapped by
+// NewError, is labelled UnknownTransactionCommitResult: the transaction may
+// have been committed, so only the commit may be retried.
+func IsUnknownCommitResult(err error) bool {
+	return hasErrorLabel(err, labelUnknownCommitResult)
+}
+
+// hasErrorLabel reports whether the driver error in err carries label,
+// looking through the errors.Retryable and errors.AppError wrappers.
+func hasErrorLabel(err error, label string) bool {
+	for err != nil {
+		var labeled mongo.LabeledError
+		if errors.As(err, &labeled) {
+			return labeled.HasErrorLabel(label)
+		}
+		switch e := err.(type) {
+		case *appErr.Retryable:
+			err = e.Errors
+		case *appErr.AppError:
+			err = e.Errors
+		default:
+			return false
 		}
 	}
+	return false
+}
 
+// isValidationError reports whether err is a document validation failure (code 121).
+func isValidationError(err error) bool {
+	var srvErr mongo.ServerError
+	if errors.As(err, &srvErr) {
+		return srvErr.HasErrorCode(codeDocumentValidationFailure)
+	}
 	return false
 }
 
+// duplicateKey returns a readable representation of the key that caused
+// a duplicate key error, e.g. `{"_id": "01J..."}`. It prefers the keyValue
+// document reported by the server and falls back to parsing the message.
+func duplicateKey(err error) string {
+	var writeErrs []mongo.WriteError
+	var writeEx mongo.WriteException
+	var bulkEx mongo.BulkWriteException
+	switch {
+	case errors.As(err, &writeEx):
+		writeErrs = writeEx.WriteErrors
+	case errors.As(err, &bulkEx):
+		for _, we := range bulkEx.WriteErrors {
+			writeErrs = append(writeErrs, we.WriteError)
+		}
+	}
+	for _, we := range writeErrs {
+		if !mongo.IsDuplicateKeyError(we) {
+			continue
+		}
+		if kv, ok := we.Raw.Lookup("keyValue").DocumentOK(); ok {
+			return kv.String()
+		}
+		if m := dupKeyPattern.FindStringSubmatch(we.Message); len(m) == 2 {
+			return m[1]
+		}
+	}
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		if kv, ok := cmdErr.Raw.Lookup("keyValue").DocumentOK(); ok {
+			return kv.String()
+		}
+	}
+	if m := dupKeyPattern.FindStringSubmatch(err.Error()); len(m) == 2 {
+		return m[1]
+	}
+	return ""
+}
+
+// NewError maps a MongoDB driver error into an application error:
+//   - mongo.ErrNoDocuments        -> codes.DataNotFound
+//   - duplicate key (E11000)      -> codes.Conflict with the offending key
+//   - validation failure (121)    -> codes.BadRequest
+//   - transient / labelled errors -> errors.Retryable
+//
+// Any other error is returned unchanged.
 func NewError(ctx context.Context, err error) error {
 	if err == nil {
 		return nil
 	}
-	if isRetryable(err) {
-		err = appErr.NewRetryable(err)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return appErr.New(codes.DataNotFound, "data not found", err)
+	case mongo.IsDuplicateKeyError(err):
+		message := "data already exists"
+		if key := duplicateKey(err); key != "" {
+			message = fmt.Sprintf("data already exists with key %s", key)
+		}
+		return appErr.New(codes.Conflict, message, err)
+	case isValidationError(err):
+		return appErr.NewBadRequest("document failed validation", err)
+	case isRetryable(err):
+		return appErr.NewRetryable(err)
 	}
 	return err
 }
@@ -0,0 +1,98 @@
+package mongox_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"research-apm/pkg/database/mongox"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// TestNewErrorNil ensures a nil error stays nil.
+func TestNewErrorNil(t *testing.T) {
+	assert.Nil(t, mongox.NewError(context.Background(), nil))
+}
+
+// TestNewErrorNoDocuments verifies that mongo.ErrNoDocuments maps to DataNotFound.
+func TestNewErrorNoDocuments(t *testing.T) {
+	err := mongox.NewError(context.Background(), fmt.Errorf("find user: %w", mongo.ErrNoDocuments))
+
+	appErr, ok := err.(*errors.AppError)
+	assert.True(t, ok)
+	assert.Equal(t, codes.DataNotFound, appErr.Code)
+	assert.False(t, appErr.IsRetryable)
+}
+
+// TestNewErrorDuplicateKey verifies that an E11000 write error maps to Conflict
+// and that the offending key is included in the message.
+func TestNewErrorDuplicateKey(t *testing.T) {
+	err := mongox.NewError(context.Background(), mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{{
+			Code:    11000,
+			Message: `E11000 duplicate key error collection: research_apm.user index: _id_ dup key: { _id: "01K0" }`,
+		}},
+	})
+
+	appErr, ok := err.(*errors.AppError)
+	assert.True(t, ok)
+	assert.Equal(t, codes.Conflict, appErr.Code)
+	assert.Contains(t, appErr.Message, `{ _id: "01K0" }`)
+}
+
+// TestNewErrorValidation verifies that a document validation failure maps to BadRequest.
+func TestNewErrorValidation(t *testing.T) {
+	err := mongox.NewError(context.Background(), mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{{Code: 121, Message: "Document failed validation"}},
+	})
+
+	appErr, ok := err.(*errors.AppError)
+	assert.True(t, ok)
+	assert.Equal(t, codes.BadRequest, appErr.Code)
+}
+
+// TestNewErrorRetryable covers both the retryable code list and the error labels.
+func TestNewErrorRetryable(t *testing.T) {
+	cases := map[string]error{
+		"code":                mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"},
+		"write concern":       mongo.WriteException{WriteConcernError: &mongo.WriteConcernError{Code: 91}},
+		"retryable label":     mongo.CommandError{Code: 1, Labels: []string{"RetryableWriteError"}},
+		"transient label":     mongo.WriteException{Labels: []string{"TransientTransactionError"}},
+		"context deadline":    context.DeadlineExceeded,
+		"wrapped server code": fmt.Errorf("insert: %w", mongo.CommandError{Code: 11602}),
+	}
+	for name, in := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, errors.IsRetryable(mongox.NewError(context.Background(), in)))
+		})
+	}
+}
+
+// TestNewErrorPassthrough ensures unknown errors are returned unchanged.
+func TestNewErrorPassthrough(t *testing.T) {
+	in := mongo.CommandError{Code: 2, Name: "BadValue"}
+	assert.Equal(t, error(in), mongox.NewError(context.Background(), in))
+}
+
+// TestTransactionLabels verifies that the transaction labels are found
+// through the wrappers of NewError.
+func TestTransactionLabels(t *testing.T) {
+	transient := mongo.CommandError{Code: 112, Labels: []string{"TransientTransactionError"}}
+	unknown := mongo.CommandError{Code: 50, Labels: []string{"UnknownTransactionCommitResult"}}
+	retryableWrite := mongo.CommandError{Code: 1, Labels: []string{"RetryableWriteError"}}
+
+	assert.True(t, mongox.IsTransientTransactionError(transient))
+	assert.True(t, mongox.IsTransientTransactionError(mongox.NewError(context.Background(), transient)))
+	assert.True(t, mongox.IsTransientTransactionError(errors.Wrap(codes.Internal, "gagal", mongox.NewError(context.Background(), transient))))
+	assert.False(t, mongox.IsTransientTransactionError(mongox.NewError(context.Background(), retryableWrite)))
+	assert.False(t, mongox.IsTransientTransactionError(unknown))
+	assert.False(t, mongox.IsTransientTransactionError(nil))
+
+	assert.True(t, mongox.IsUnknownCommitResult(mongox.NewError(context.Background(), unknown)))
+	assert.False(t, mongox.IsUnknownCommitResult(mongox.NewError(context.Background(), transient)))
+	assert.False(t, mongox.IsUnknownCommitResult(fmt.Errorf("commit failed")))
+}
@@ -0,0 +1,16 @@
+package mongox
+
+import (
+	"context"
+	"research-apm/pkg/health"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
+
+// HealthCheck returns a readiness check that pings the primary.
+func HealthCheck(client *mongo.Client) health.CheckFunc {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	}
+}
@@ -0,0 +1,61 @@
+package mongox
+
+import (
+	"research-apm/pkg/metrics"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// poolStats counts the connections of a client from its pool events,
+// since the driver does not expose pool stats directly.
+type poolStats struct {
+	open           atomic.Int64
+	inUse          atomic.Int64
+	checkoutFailed atomic.Int64
+}
+
+// clientPools maps a *mongo.Client to the poolStats filled by its monitor.
+var clientPools sync.Map
+
+func newPoolMonitor(stats *poolStats) *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			switch evt.Type {
+			case event.ConnectionCreated:
+				stats.open.Add(1)
+			case event.ConnectionClosed:
+				stats.open.Add(-1)
+			case event.ConnectionCheckedOut:
+				stats.inUse.Add(1)
+			case event.ConnectionCheckedIn:
+				stats.inUse.Add(-1)
+			case event.ConnectionCheckOutFailed:
+				stats.checkoutFailed.Add(1)
+			}
+		},
+	}
+}
+
+// RegisterMetrics exposes the connection pool stats of a client created by
+// NewClient in metrics.Default, labeled with client=name.
+func RegisterMetrics(name string, client *mongo.Client) {
+	v, ok := clientPools.Load(client)
+	if !ok {
+		return
+	}
+	stats := v.(*poolStats)
+	labels := metrics.Labels{"client": name}
+	reg := metrics.Default
+	reg.GaugeFunc("mongodb_pool_open_connections", "Number of established connections.", labels, func() float64 {
+		return float64(stats.open.Load())
+	})
+	reg.GaugeFunc("mongodb_pool_in_use_connections", "Number of connections checked out of the pool.", labels, func() float64 {
+		return float64(stats.inUse.Load())
+	})
+	reg.CounterFunc("mongodb_pool_checkout_failed", "Number of failed connection check outs.", labels, func() float64 {
+		return float64(stats.checkoutFailed.Load())
+	})
+}
@@ -14,6 +14,7 @@ type Config struct {
 	MinPoolSize     uint64
 	MaxPoolSize     uint64
 	MaxConnIdleTime time.Duration
+	UseApm          bool // Record a span per command with the active tracer backend
 }
 
 func NewClient(cfg Config) (*mongo.Client, error) {
@@ -22,6 +23,11 @@ func NewClient(cfg Config) (*mongo.Client, error) {
 		SetMinPoolSize(cfg.MinPoolSize).
 		SetMaxPoolSize(cfg.MaxPoolSize).
 		SetMaxConnIdleTime(cfg.MaxConnIdleTime)
+	if cfg.UseApm {
+		clientOpts.SetMonitor(newCommandMonitor())
+	}
+	stats := &poolStats{}
+	clientOpts.SetPoolMonitor(newPoolMonitor(stats))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -32,14 +38,17 @@ func NewClient(cfg Config) (*mongo.Client, error) {
 	}
 
 	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(ctx)
 		return nil, fmt.Errorf("ping failed: %s", err.Error())
 	}
+	clientPools.Store(client, stats)
 
 	return client, nil
 }
 
 func Disconnect(dbClient *mongo.Client) {
 	if dbClient != nil {
+		clientPools.Delete(dbClient)
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := dbClient.Disconnect(ctx); err != nil {
@@ -0,0 +1,67 @@
+package mongox
+
+import (
+	"context"
+	"net"
+	"research-apm/pkg/tracer"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// commandKey identifies an in-flight command across the monitor callbacks.
+type commandKey struct {
+	connectionID string
+	requestID    int64
+}
+
+// newCommandMonitor creates a command monitor that records a span for every
+// MongoDB command through pkg/tracer, so it follows the active tracing backend.
+func newCommandMonitor() *event.CommandMonitor {
+	var spans sync.Map // commandKey -> tracer.Span
+
+	finish := func(evt event.CommandFinishedEvent, err error) {
+		v, ok := spans.LoadAndDelete(commandKey{evt.ConnectionID, evt.RequestID})
+		if !ok {
+			return
+		}
+		span := v.(tracer.Span)
+		// The error is returned to the repository, which reports it.
+		span.SetOutcome(err)
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			host, port := connectionAddress(evt.ConnectionID)
+			_, span := tracer.StartDBSpan(ctx, tracer.DBSpan{
+				System:    "mongodb",
+				Instance:  evt.DatabaseName,
+				Operation: evt.CommandName,
+				Address:   host,
+				Port:      port,
+			})
+			spans.Store(commandKey{evt.ConnectionID, evt.RequestID}, span)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.CommandFinishedEvent, nil)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.CommandFinishedEvent, evt.Failure)
+		},
+	}
+}
+
+// connectionAddress extracts the server host and port from a driver
+// connection ID of the form "host:port[-N]".
+func connectionAddress(connectionID string) (string, int) {
+	addr, _, _ := strings.Cut(connectionID, "[")
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, 0
+	}
+	port, _ := strconv.Atoi(p)
+	return host, port
+}
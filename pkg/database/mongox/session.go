@@ -0,0 +1,92 @@
+package mongox
+
+import (
+	"context"
+	"fmt"
+	"research-apm/pkg/retry"
+	"research-apm/pkg/tracer"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// SessionConfig defines how WithSessionConfig runs a transaction.
+type SessionConfig struct {
+	// Policy retries the whole transaction on a TransientTransactionError,
+	// and the commit alone on an UnknownTransactionCommitResult. Its
+	// Classifier is ignored. Zero fields use the retry defaults.
+	Policy  retry.Policy
+	Options *options.TransactionOptionsBuilder // Optional read/write concern and read preference.
+}
+
+// WithSession runs fn in a transaction of client with the default SessionConfig.
+func WithSession(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error) error {
+	return WithSessionConfig(ctx, client, SessionConfig{}, fn)
+}
+
+// WithSessionConfig runs fn in a transaction of a session of client. The
+// session is carried by the context passed to fn, so every operation of
+// client made with it is part of the transaction. The transaction is
+// committed when fn returns nil and aborted when fn returns an error.
+//
+// MongoDB has no savepoints: a call inside the transaction of the same
+// client joins it, and an error of the inner fn aborts the whole
+// transaction once it reaches the outermost call. The outermost call is
+// traced as one span and retried as a whole on transient failures, so fn
+// may run more than once and must not have side effects outside client.
+// Transactions need a replica set or a sharded cluster.
+func WithSessionConfig(ctx context.Context, client *mongo.Client, cfg SessionConfig, fn func(ctx context.Context) error) error {
+	if sess := mongo.SessionFromContext(ctx); sess != nil && sess.Client() == client {
+		return fn(ctx)
+	}
+
+	if cfg.Policy.Name == "" {
+		cfg.Policy.Name = "mongox.session"
+	}
+	txPolicy, commitPolicy := cfg.Policy, cfg.Policy
+	txPolicy.Classifier = IsTransientTransactionError
+	commitPolicy.Name += ".commit"
+	commitPolicy.Classifier = IsUnknownCommitResult
+	return tracer.Do(ctx, "mongox.transaction", func(ctx context.Context) error {
+		return retry.Do(ctx, txPolicy, func(ctx context.Context) error {
+			return runTransaction(ctx, client, cfg.Options, commitPolicy, fn)
+		})
+	})
+}
+
+// runTransaction runs one attempt of a transaction in a new session.
+func runTransaction(ctx context.Context, client *mongo.Client, opts *options.TransactionOptionsBuilder, commitPolicy retry.Policy, fn func(ctx context.Context) error) error {
+	sess, err := client.StartSession()
+	if err != nil {
+		return NewError(ctx, err)
+	}
+	defer sess.EndSession(context.WithoutCancel(ctx))
+
+	var txOpts []options.Lister[options.TransactionOptions]
+	if opts != nil {
+		txOpts = append(txOpts, opts)
+	}
+	if err := sess.StartTransaction(txOpts...); err != nil {
+		return NewError(ctx, err)
+	}
+	sessCtx := mongo.NewSessionContext(ctx, sess)
+
+	committing := false
+	defer func() {
+		if committing {
+			return
+		}
+		// Abort even when ctx is done, and on panic.
+		if err := sess.AbortTransaction(context.WithoutCancel(ctx)); err != nil {
+			fmt.Println("[ERROR] mongox: abort transaction", err.Error())
+		}
+	}()
+
+	if err := fn(sessCtx); err != nil {
+		return err
+	}
+	committing = true
+	return retry.Do(ctx, commitPolicy, func(ctx context.Context) error {
+		return NewError(ctx, sess.CommitTransaction(mongo.NewSessionContext(ctx, sess)))
+	})
+}
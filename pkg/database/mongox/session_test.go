@@ -0,0 +1,66 @@
+package mongox_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"research-apm/pkg/database/mongox"
+	"research-apm/pkg/retry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// newOfflineClient returns a client of an unreachable server. Sessions and
+// transactions without operations are handled by the driver alone.
+func newOfflineClient(t *testing.T) *mongo.Client {
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(100 * time.Millisecond))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	return client
+}
+
+// TestWithSessionRetries verifies that only a TransientTransactionError
+// runs the transaction again.
+func TestWithSessionRetries(t *testing.T) {
+	client := newOfflineClient(t)
+	cfg := mongox.SessionConfig{Policy: retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond}}
+	cases := map[string]struct {
+		err   error
+		calls int
+	}{
+		"success":         {nil, 1},
+		"transient":       {mongo.CommandError{Code: 112, Labels: []string{"TransientTransactionError"}}, 3},
+		"retryable write": {mongo.CommandError{Code: 91, Labels: []string{"RetryableWriteError"}}, 1},
+		"network":         {mongox.NewError(context.Background(), context.DeadlineExceeded), 1},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			err := mongox.WithSessionConfig(context.Background(), client, cfg, func(ctx context.Context) error {
+				calls++
+				assert.NotNil(t, mongo.SessionFromContext(ctx), "runs in a session")
+				return tc.err
+			})
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.calls, calls)
+		})
+	}
+}
+
+// TestWithSessionNested verifies that a nested call joins the transaction
+// of the same client.
+func TestWithSessionNested(t *testing.T) {
+	client := newOfflineClient(t)
+	err := mongox.WithSession(context.Background(), client, func(ctx context.Context) error {
+		outer := mongo.SessionFromContext(ctx)
+		return mongox.WithSession(ctx, client, func(ctx context.Context) error {
+			assert.Same(t, outer, mongo.SessionFromContext(ctx))
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,351 @@
+package redisx
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand/v2"
+	"research-apm/pkg/cache"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
+)
+
+// Codec encodes the values stored by a Cache.
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec encodes values as JSON. It is the default codec.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec encodes values with encoding/gob, which is more compact than
+// JSON for large structs but only readable by Go.
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// Entries are stored with a one byte marker, so a cached "not found"
+// can be told apart from a value.
+const (
+	markerValue    = 'v'
+	markerNotFound = 'n'
+)
+
+// setAndUnlockScript stores the entry with its TTL and releases the load
+// lock in one step, so a crash cannot leave an entry without TTL or a
+// stale lock behind.
+//
+//	KEYS[1] = entry key, KEYS[2] = lock key
+//	ARGV[1] = entry, ARGV[2] = TTL (ms), ARGV[3] = lock token
+var setAndUnlockScript = redis.NewScript(`
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+if redis.call('GET', KEYS[2]) == ARGV[3] then
+	redis.call('DEL', KEYS[2])
+end
+return 1
+`)
+
+// unlockScript releases the load lock if it is still held by the token.
+//
+//	KEYS[1] = lock key, ARGV[1] = lock token
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// CacheConfig configures a Cache. Zero values fall back to the defaults
+// documented on each field.
+type CacheConfig[T any] struct {
+	Name   string   // Metrics label; empty keeps the cache out of metrics.Default.
+	Prefix string   // Prepended to every key as "<prefix>:{<key>}".
+	Codec  Codec[T] // Value encoding (default JSONCodec).
+	// Jitter randomizes every TTL by up to this fraction, e.g. 0.1 for ±10%,
+	// so entries written together do not expire together (default 0.1).
+	// Set it to a negative value to disable it.
+	Jitter float64
+	// NegativeTTL caches a loader error with code DATA_NOT_FOUND for this
+	// long, so missing keys do not hit the database on every request.
+	// Zero disables negative caching.
+	NegativeTTL time.Duration
+	// LockTTL bounds how long a load holds the lock that keeps the other
+	// replicas from loading the same key, and how long the loader may run
+	// (default 10s).
+	LockTTL time.Duration
+	// PollInterval is how often a replica waiting for another one's load
+	// checks the cache (default 50ms).
+	PollInterval time.Duration
+}
+
+// Cache is a cache-aside cache of T values in Redis. Concurrent misses of
+// a key are coalesced: within a process by singleflight, across replicas by
+// a Redis lock, so the loader runs once per key and TTL.
+//
+// Values returned to concurrent callers of GetOrLoad are shared and must
+// not be modified.
+type Cache[T any] struct {
+	client redis.Cmdable
+	cfg    CacheConfig[T]
+	stats  *cache.Stats
+	group  singleflight.Group
+}
+
+// NewCache creates a cache storing its entries in client. Its hits and
+// misses are exposed in metrics.Default with tier="l2".
+func NewCache[T any](client redis.Cmdable, cfg CacheConfig[T]) *Cache[T] {
+	if cfg.Codec == nil {
+		cfg.Codec = JSONCodec[T]{}
+	}
+	if cfg.Jitter == 0 {
+		cfg.Jitter = 0.1
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = 10 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 50 * time.Millisecond
+	}
+	return &Cache[T]{client: client, cfg: cfg, stats: cache.NewStats(cfg.Name, "l2")}
+}
+
+// key returns the Redis key of an entry. The key is a hash tag so the
+// entry and its load lock share a Redis Cluster slot, as the scripts
+// using both require.
+func (c *Cache[T]) key(key string) string {
+	if c.cfg.Prefix == "" {
+		return "{" + key + "}"
+	}
+	return c.cfg.Prefix + ":{" + key + "}"
+}
+
+// lockKey returns the Redis key of the load lock of an entry.
+func (c *Cache[T]) lockKey(key string) string {
+	return c.key(key) + ":lock"
+}
+
+// Get returns the cached value of key and whether it was found.
+// A cached "not found" is returned as its DATA_NOT_FOUND error.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	data, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if err == redis.Nil {
+		return zero, false, nil
+	}
+	if err != nil {
+		return zero, false, err
+	}
+	return c.decode(key, data)
+}
+
+func (c *Cache[T]) decode(key string, data []byte) (T, bool, error) {
+	var zero T
+	if len(data) == 0 {
+		return zero, false, fmt.Errorf("redisx cache: empty entry %s", key)
+	}
+	switch data[0] {
+	case markerValue:
+		v, err := c.cfg.Codec.Unmarshal(data[1:])
+		if err != nil {
+			return zero, false, fmt.Errorf("redisx cache: decode %s: %s", key, err.Error())
+		}
+		return v, true, nil
+	case markerNotFound:
+		return zero, true, errors.New(codes.DataNotFound, string(data[1:]), fmt.Errorf("%s not found (cached)", key))
+	default:
+		return zero, false, fmt.Errorf("redisx cache: unknown entry %s", key)
+	}
+}
+
+// Set stores value under key for ttl (with jitter).
+func (c *Cache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, c.key(key), data, c.jitter(ttl)).Err()
+}
+
+// Delete removes the entries of keys. The keys are deleted one by one,
+// in a pipeline, since they may be in different Cluster slots.
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			pipe.Del(ctx, c.key(k))
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *Cache[T]) encode(value T) ([]byte, error) {
+	data, err := c.cfg.Codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("redisx cache: encode: %s", err.Error())
+	}
+	return append([]byte{markerValue}, data...), nil
+}
+
+func (c *Cache[T]) jitter(ttl time.Duration) time.Duration {
+	if c.cfg.Jitter <= 0 {
+		return ttl
+	}
+	delta := (mathrand.Float64()*2 - 1) * c.cfg.Jitter * float64(ttl)
+	return ttl + time.Duration(delta)
+}
+
+// GetOrLoad returns the cached value of key, or loads it with loader and
+// caches it for ttl. A loader error with code DATA_NOT_FOUND is cached for
+// CacheConfig.NegativeTTL; other errors are not cached.
+//
+// Redis failures do not fail the call: the value is loaded without caching.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	v, ok, err := c.Get(ctx, key)
+	if ok {
+		c.stats.Hit()
+		return v, err
+	}
+	c.stats.Miss()
+	if err != nil {
+		fmt.Println("[ERROR] redisx cache: get", key, err.Error())
+		return loader(ctx)
+	}
+
+	// The flight runs without the caller's cancellation, so one canceled
+	// caller does not fail the others waiting for the same key. The loader
+	// is bounded by LockTTL instead.
+	ch := c.group.DoChan(key, func() (any, error) {
+		return c.load(context.WithoutCancel(ctx), key, ttl, loader)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			var zero T
+			return zero, res.Err
+		}
+		v, _ := res.Val.(T)
+		return v, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// load runs loader under the Redis lock of key, or waits for the replica
+// holding it to fill the cache.
+func (c *Cache[T]) load(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	lockKey := c.lockKey(key)
+	token := newToken()
+	locked, err := c.client.SetNX(ctx, lockKey, token, c.cfg.LockTTL).Result()
+	if err != nil {
+		fmt.Println("[ERROR] redisx cache: lock", key, err.Error())
+		return c.callLoader(ctx, loader)
+	}
+	if !locked {
+		if v, ok, err := c.wait(ctx, key); ok {
+			return v, err
+		}
+		// The other load failed or took too long; load without caching.
+		return c.callLoader(ctx, loader)
+	}
+
+	v, loadErr := c.callLoader(ctx, loader)
+	var entry []byte
+	entryTTL := c.jitter(ttl)
+	switch {
+	case loadErr == nil:
+		entry, err = c.encode(v)
+	case c.cfg.NegativeTTL > 0 && isNotFound(loadErr):
+		entry = append([]byte{markerNotFound}, errors.FromError(loadErr).Message...)
+		entryTTL = c.cfg.NegativeTTL
+	}
+	if entry == nil || err != nil {
+		if err != nil {
+			fmt.Println("[ERROR]", err.Error())
+		}
+		unlockScript.Run(ctx, c.client, []string{lockKey}, token)
+		return v, loadErr
+	}
+	if err := setAndUnlockScript.Run(ctx, c.client, []string{c.key(key), lockKey}, entry, entryTTL.Milliseconds(), token).Err(); err != nil {
+		fmt.Println("[ERROR] redisx cache: set", key, err.Error())
+	}
+	return v, loadErr
+}
+
+// callLoader runs loader with a deadline of LockTTL: past it the lock has
+// expired and another replica may be loading the same key.
+// It runs in the singleflight goroutine, which re-panics out of reach of
+// any recover and kills the process, so a loader panic is returned as an
+// INTERNAL_ERROR to every waiting caller instead.
+func (c *Cache[T]) callLoader(ctx context.Context, loader func(ctx context.Context) (T, error)) (v T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero T
+			v, err = zero, errors.New(codes.Internal, "failed to load the data", fmt.Errorf("redisx cache: loader panicked: %v", r))
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.LockTTL)
+	defer cancel()
+	return loader(ctx)
+}
+
+// wait polls the cache until the lock holder has stored key or the lock
+// would have expired.
+func (c *Cache[T]) wait(ctx context.Context, key string) (T, bool, error) {
+	ticker := time.NewTicker(c.cfg.PollInterval)
+	defer ticker.Stop()
+	deadline := time.After(c.cfg.LockTTL)
+	for {
+		select {
+		case <-deadline:
+			var zero T
+			return zero, false, nil
+		case <-ticker.C:
+			v, ok, err := c.Get(ctx, key)
+			if ok || err != nil {
+				return v, ok, err
+			}
+		}
+	}
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*errors.AppError)
+	return ok && e.Code == codes.DataNotFound
+}
+
+// newToken returns a random lock token, so only the holder releases a lock.
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
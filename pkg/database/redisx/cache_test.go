@@ -0,0 +1,196 @@
+package redisx_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"research-apm/pkg/database/redisx"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type profile struct {
+	Name  string
+	Roles []string
+}
+
+// TestCodecs verifies that the codecs round trip a value.
+func TestCodecs(t *testing.T) {
+	v := profile{Name: "budi", Roles: []string{"admin"}}
+	for name, codec := range map[string]redisx.Codec[profile]{
+		"json": redisx.JSONCodec[profile]{},
+		"gob":  redisx.GobCodec[profile]{},
+	} {
+		data, err := codec.Marshal(v)
+		assert.NoError(t, err, name)
+		got, err := codec.Unmarshal(data)
+		assert.NoError(t, err, name)
+		assert.Equal(t, v, got, name)
+	}
+}
+
+// TestGetOrLoadRedisDown verifies that an unreachable Redis degrades to
+// calling the loader instead of failing the request.
+func TestGetOrLoadRedisDown(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	cache := redisx.NewCache(client, redisx.CacheConfig[profile]{Prefix: "test"})
+
+	calls := 0
+	v, err := cache.GetOrLoad(context.Background(), "budi", time.Minute, func(ctx context.Context) (profile, error) {
+		calls++
+		return profile{Name: "budi"}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "budi", v.Name)
+	assert.Equal(t, 1, calls)
+}
+
+// TestGetOrLoadCoalesces verifies that concurrent misses of a key in one
+// process run the loader once.
+func TestGetOrLoadCoalesces(t *testing.T) {
+	_, client := newMiniRedis(t)
+	cache := redisx.NewCache(client, redisx.CacheConfig[profile]{Prefix: "test"})
+
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(context.Background(), "budi", time.Minute, func(ctx context.Context) (profile, error) {
+				calls.Add(1)
+				time.Sleep(50 * time.Millisecond)
+				return profile{Name: "budi"}, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "budi", v.Name)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+// TestGetOrLoadAcrossReplicas verifies that a replica missing a key another
+// replica is loading waits for its value instead of loading it again.
+func TestGetOrLoadAcrossReplicas(t *testing.T) {
+	mr, client := newMiniRedis(t)
+	cfg := redisx.CacheConfig[profile]{Prefix: "test", PollInterval: 10 * time.Millisecond}
+	first, second := redisx.NewCache(client, cfg), redisx.NewCache(client, cfg)
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		first.GetOrLoad(context.Background(), "budi", time.Minute, func(ctx context.Context) (profile, error) {
+			<-release
+			return profile{Name: "first"}, nil
+		})
+	}()
+	assert.Eventually(t, func() bool { return mr.Exists("test:{budi}:lock") }, time.Second, 5*time.Millisecond, "lock taken")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	v, err := second.GetOrLoad(context.Background(), "budi", time.Minute, func(ctx context.Context) (profile, error) {
+		return profile{Name: "second"}, nil
+	})
+	<-done
+	assert.NoError(t, err)
+	assert.Equal(t, "first", v.Name)
+}
+
+// TestGetOrLoadStoresWithTTL verifies that the entry is stored with its TTL
+// and that the load lock is released.
+func TestGetOrLoadStoresWithTTL(t *testing.T) {
+	mr, client := newMiniRedis(t)
+	cache := redisx.NewCache(client, redisx.CacheConfig[profile]{Prefix: "test", Jitter: -1})
+
+	_, err := cache.GetOrLoad(context.Background(), "budi", time.Minute, func(ctx context.Context) (profile, error) {
+		return profile{Name: "budi"}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test:{budi}"}, mr.Keys(), "entry and lock share the hash tag")
+	assert.Equal(t, time.Minute, mr.TTL("test:{budi}"))
+
+	mr.FastForward(time.Minute)
+	_, ok, err := cache.Get(context.Background(), "budi")
+	assert.NoError(t, err)
+	assert.False(t, ok, "expired")
+
+	cache.Set(context.Background(), "budi", profile{Name: "budi"}, time.Minute)
+	cache.Set(context.Background(), "siti", profile{Name: "siti"}, time.Minute)
+	assert.NoError(t, cache.Delete(context.Background(), "budi", "siti"))
+	assert.Empty(t, mr.Keys())
+}
+
+// TestGetOrLoadNegative verifies that a DATA_NOT_FOUND loader error is
+// cached for NegativeTTL and other errors are not cached.
+func TestGetOrLoadNegative(t *testing.T) {
+	mr, client := newMiniRedis(t)
+	cache := redisx.NewCache(client, redisx.CacheConfig[profile]{Prefix: "test", NegativeTTL: time.Minute})
+
+	calls := 0
+	notFound := func(ctx context.Context) (profile, error) {
+		calls++
+		return profile{}, errors.New(codes.DataNotFound, "user tidak ditemukan", nil)
+	}
+	for range 2 {
+		_, err := cache.GetOrLoad(context.Background(), "budi", time.Hour, notFound)
+		assert.Equal(t, codes.DataNotFound, errors.FromError(err).Code)
+		assert.Equal(t, "user tidak ditemukan", errors.FromError(err).Message)
+	}
+	assert.Equal(t, 1, calls)
+
+	mr.FastForward(time.Minute)
+	cache.GetOrLoad(context.Background(), "budi", time.Hour, notFound)
+	assert.Equal(t, 2, calls)
+
+	failed := 0
+	for range 2 {
+		cache.GetOrLoad(context.Background(), "siti", time.Hour, func(ctx context.Context) (profile, error) {
+			failed++
+			return profile{}, errors.New(codes.Internal, "gagal", nil)
+		})
+	}
+	assert.Equal(t, 2, failed)
+}
+
+// TestGetOrLoadDeadline verifies that the detached loader is bounded by LockTTL.
+func TestGetOrLoadDeadline(t *testing.T) {
+	_, client := newMiniRedis(t)
+	cache := redisx.NewCache(client, redisx.CacheConfig[profile]{Prefix: "test", LockTTL: 50 * time.Millisecond})
+
+	_, err := cache.GetOrLoad(context.Background(), "budi", time.Minute, func(ctx context.Context) (profile, error) {
+		<-ctx.Done()
+		return profile{}, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// TestGetOrLoadPanic verifies that a loader panic is returned as an
+// INTERNAL_ERROR instead of crashing the process, and releases the lock.
+func TestGetOrLoadPanic(t *testing.T) {
+	server, client := newMiniRedis(t)
+	cache := redisx.NewCache(client, redisx.CacheConfig[profile]{Prefix: "test"})
+
+	_, err := cache.GetOrLoad(context.Background(), "budi", time.Minute, func(ctx context.Context) (profile, error) {
+		panic("nil map")
+	})
+	assert.Equal(t, codes.Internal, errors.FromError(err).Code)
+	assert.ErrorContains(t, errors.FromError(err).Errors, "nil map")
+	assert.False(t, server.Exists("test:{budi}:lock"))
+
+	v, err := cache.GetOrLoad(context.Background(), "budi", time.Minute, func(ctx context.Context) (profile, error) {
+		return profile{Name: "budi"}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "budi", v.Name)
+}
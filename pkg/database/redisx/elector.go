@@ -0,0 +1,169 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotLeader is returned by Elector.IsLeader on the followers.
+var ErrNotLeader = errors.New("redisx: not the leader")
+
+// ElectorConfig configures an Elector. Zero values fall back to the
+// defaults documented on each field.
+type ElectorConfig struct {
+	Key string        // Name of the election, e.g. the service name.
+	TTL time.Duration // Leadership lease; a crashed leader is replaced after it (default 15s).
+	// Margin is subtracted from the lease, so a leader that cannot renew it
+	// steps down before another replica may take over, despite clock drift
+	// (default TTL/10).
+	Margin time.Duration
+	// RenewInterval is how often the leader renews its lease and the
+	// followers try to take it over (default TTL/3).
+	RenewInterval time.Duration
+}
+
+// Elector elects one leader among the replicas using a Lock that the
+// leader keeps renewing. It implements gocron.Elector, so only the leader
+// runs the scheduled jobs:
+//
+//	elector := redisx.NewElector(locker, redisx.ElectorConfig{Key: "alert"})
+//	elector.Start(ctx)
+//	scheduler, err := gocron.NewScheduler(gocron.WithDistributedElector(elector))
+type Elector struct {
+	locker *Locker
+	cfg    ElectorConfig
+
+	mu       sync.Mutex
+	lock     *Lock     // held while leader
+	deadline time.Time // of the lease of lock
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewElector creates an elector campaigning with locker.
+func NewElector(locker *Locker, cfg ElectorConfig) *Elector {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 15 * time.Second
+	}
+	if cfg.RenewInterval <= 0 {
+		cfg.RenewInterval = cfg.TTL / 3
+	}
+	if cfg.Margin <= 0 {
+		cfg.Margin = cfg.TTL / 10
+	}
+	return &Elector{locker: locker, cfg: cfg, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// Start campaigns in the background until Stop. It must be called once.
+func (e *Elector) Start(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.cfg.RenewInterval)
+		defer ticker.Stop()
+		for {
+			e.campaign(ctx)
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// campaign renews the lease of the leader, or tries to take it. The Redis
+// calls are made without e.mu, so IsLeader does not wait for them, and are
+// bounded by the margin: a call that takes longer leaves too little of the
+// lease to be used anyway.
+func (e *Elector) campaign(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.Margin)
+	defer cancel()
+
+	e.mu.Lock()
+	lock := e.lock
+	e.mu.Unlock()
+
+	if lock != nil {
+		err := lock.Refresh(ctx, e.cfg.TTL)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if err != nil {
+			fmt.Println("[WARN] election", e.cfg.Key, "leadership lost:", err.Error())
+			e.lock = nil
+			return
+		}
+		e.deadline = lock.Deadline()
+		return
+	}
+	lock, err := e.locker.TryLock(ctx, "election:"+e.cfg.Key, e.cfg.TTL)
+	if err != nil {
+		if err != ErrNotObtained {
+			fmt.Println("[ERROR] election", e.cfg.Key, err.Error())
+		}
+		return
+	}
+	fmt.Println("[INFO] election", e.cfg.Key, "became leader, term", lock.Fence())
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lock = lock
+	e.deadline = lock.Deadline()
+}
+
+// IsLeader returns nil on the leader and ErrNotLeader otherwise. A leader
+// whose lease was not renewed in time, e.g. while Redis is unreachable, is
+// not the leader anymore: the lease may have expired and been taken over.
+func (e *Elector) IsLeader(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.leading() {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// leading reports whether the lease is held and not about to expire.
+// e.mu must be held.
+func (e *Elector) leading() bool {
+	return e.lock != nil && time.Now().Before(e.deadline.Add(-e.cfg.Margin))
+}
+
+// Term returns the fencing token of the current leadership, or 0 when
+// not the leader.
+func (e *Elector) Term() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.leading() {
+		return 0
+	}
+	return e.lock.Fence()
+}
+
+// Stop stops campaigning and resigns, so another replica takes over
+// without waiting for the lease to expire.
+func (e *Elector) Stop(ctx context.Context) error {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock == nil {
+		return nil
+	}
+	err := e.lock.Release(ctx)
+	e.lock = nil
+	if err == ErrLockLost {
+		return nil
+	}
+	return err
+}
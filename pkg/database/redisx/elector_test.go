@@ -0,0 +1,137 @@
+package redisx_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"research-apm/pkg/database/redisx"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// newElector starts an elector on its own connection to mr.
+func newElector(t *testing.T, addr string, cfg redisx.ElectorConfig) (*redisx.Elector, redis.UniversalClient) {
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	elector := redisx.NewElector(redisx.NewLocker(client, "test"), cfg)
+	elector.Start(context.Background())
+	t.Cleanup(func() { elector.Stop(context.Background()) })
+	return elector, client
+}
+
+func isLeader(e *redisx.Elector) bool {
+	return e.IsLeader(context.Background()) == nil
+}
+
+// TestElectorFailover verifies that a single leader is elected, that a
+// resigning leader is replaced at once and a crashed one after its lease.
+func TestElectorFailover(t *testing.T) {
+	mr, _ := newMiniRedis(t)
+	cfg := redisx.ElectorConfig{Key: "alert", TTL: time.Minute, RenewInterval: 10 * time.Millisecond}
+	first, firstClient := newElector(t, mr.Addr(), cfg)
+	assert.Eventually(t, func() bool { return isLeader(first) }, time.Second, 5*time.Millisecond)
+	second, _ := newElector(t, mr.Addr(), cfg)
+	third, _ := newElector(t, mr.Addr(), cfg)
+	time.Sleep(50 * time.Millisecond)
+	assert.ErrorIs(t, second.IsLeader(context.Background()), redisx.ErrNotLeader)
+	assert.ErrorIs(t, third.IsLeader(context.Background()), redisx.ErrNotLeader)
+	assert.Zero(t, second.Term())
+
+	// A crashed leader keeps the lease until it expires.
+	term := first.Term()
+	firstClient.Close()
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, isLeader(first), "cannot renew")
+	assert.False(t, isLeader(second) || isLeader(third), "lease not expired yet")
+	mr.FastForward(time.Minute)
+	assert.Eventually(t, func() bool { return isLeader(second) != isLeader(third) }, time.Second, 5*time.Millisecond)
+
+	next, follower := second, third
+	if isLeader(third) {
+		next, follower = third, second
+	}
+	assert.Greater(t, next.Term(), term)
+
+	// A resigning leader is replaced without waiting for its lease.
+	term = next.Term()
+	assert.NoError(t, next.Stop(context.Background()))
+	assert.False(t, isLeader(next))
+	assert.Eventually(t, func() bool { return isLeader(follower) }, time.Second, 5*time.Millisecond)
+	assert.Greater(t, follower.Term(), term)
+}
+
+// TestElectorLeaseDeadline verifies that a leader that has not renewed its
+// lease steps down before the lease may expire in Redis.
+func TestElectorLeaseDeadline(t *testing.T) {
+	mr, _ := newMiniRedis(t)
+	elector, _ := newElector(t, mr.Addr(), redisx.ElectorConfig{
+		Key:           "alert",
+		TTL:           200 * time.Millisecond,
+		RenewInterval: time.Hour,
+		Margin:        100 * time.Millisecond,
+	})
+	assert.Eventually(t, func() bool { return isLeader(elector) }, time.Second, 5*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.ErrorIs(t, elector.IsLeader(context.Background()), redisx.ErrNotLeader)
+	assert.Zero(t, elector.Term())
+}
+
+// TestElectorSlowRedis verifies that IsLeader does not wait for a campaign
+// stuck on Redis, and that the campaign gives up after the margin.
+func TestElectorSlowRedis(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close() // never answers
+		}
+	}()
+
+	elector, _ := newElector(t, listener.Addr().String(), redisx.ElectorConfig{Key: "alert", TTL: time.Second})
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	assert.ErrorIs(t, elector.IsLeader(context.Background()), redisx.ErrNotLeader)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, elector.Stop(ctx), "the campaign is bounded by the margin")
+}
+
+// TestElectorScheduler verifies that only the leader runs the jobs of a
+// gocron scheduler.
+func TestElectorScheduler(t *testing.T) {
+	mr, _ := newMiniRedis(t)
+	cfg := redisx.ElectorConfig{Key: "alert", TTL: time.Minute, RenewInterval: 10 * time.Millisecond}
+
+	var runs [2]atomic.Int32
+	var electors [2]*redisx.Elector
+	for i := range runs {
+		electors[i], _ = newElector(t, mr.Addr(), cfg)
+		scheduler, err := gocron.NewScheduler(gocron.WithDistributedElector(electors[i]))
+		assert.NoError(t, err)
+		_, err = scheduler.NewJob(gocron.DurationJob(20*time.Millisecond), gocron.NewTask(func() { runs[i].Add(1) }))
+		assert.NoError(t, err)
+		scheduler.Start()
+		t.Cleanup(func() { scheduler.Shutdown() })
+	}
+	assert.Eventually(t, func() bool { return runs[0].Load()+runs[1].Load() >= 3 }, time.Second, 10*time.Millisecond)
+
+	leader, follower := 0, 1
+	if isLeader(electors[1]) {
+		leader, follower = 1, 0
+	}
+	assert.True(t, isLeader(electors[leader]))
+	assert.Positive(t, runs[leader].Load())
+	assert.Zero(t, runs[follower].Load())
+}
@@ -0,0 +1,53 @@
+package redisx
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+var _ gocron.Elector = (*Elector)(nil)
+
+// JobLocker is a gocron.Locker: each run of a job is executed by the
+// replica that obtains the job's lock.
+//
+// Replicas fire at slightly different times, so the lock of a run is kept
+// for at least MinHold after a fast job ends; otherwise a replica whose
+// clock is a few milliseconds late would run the job again.
+type JobLocker struct {
+	locker  *Locker
+	ttl     time.Duration
+	minHold time.Duration
+}
+
+// NewJobLocker creates a gocron locker. ttl must exceed the longest run of
+// a job; minHold should be below the job interval (e.g. 10s for a job
+// running every minute).
+func NewJobLocker(locker *Locker, ttl, minHold time.Duration) *JobLocker {
+	return &JobLocker{locker: locker, ttl: ttl, minHold: minHold}
+}
+
+// Lock implements gocron.Locker.
+func (l *JobLocker) Lock(ctx context.Context, key string) (gocron.Lock, error) {
+	lock, err := l.locker.TryLock(ctx, "job:"+key, l.ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &jobLock{lock: lock, obtained: lock.Deadline().Add(-l.ttl), minHold: l.minHold}, nil
+}
+
+type jobLock struct {
+	lock     *Lock
+	obtained time.Time
+	minHold  time.Duration
+}
+
+// Unlock implements gocron.Lock. It lets the lock expire at MinHold after
+// it was obtained, or releases it when that has passed.
+func (l *jobLock) Unlock(ctx context.Context) error {
+	if remaining := l.minHold - time.Since(l.obtained); remaining > 0 {
+		return l.lock.Refresh(ctx, remaining)
+	}
+	return l.lock.Release(ctx)
+}
@@ -0,0 +1,43 @@
+package redisx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"research-apm/pkg/database/redisx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestJobLocker verifies that a run is locked on one replica and that the
+// lock of a fast run is kept for MinHold, then released.
+func TestJobLocker(t *testing.T) {
+	mr, client := newMiniRedis(t)
+	ctx := context.Background()
+	first := redisx.NewJobLocker(redisx.NewLocker(client, "test"), time.Minute, 10*time.Second)
+	second := redisx.NewJobLocker(redisx.NewLocker(client, "test"), time.Minute, 10*time.Second)
+
+	lock, err := first.Lock(ctx, "alert")
+	assert.NoError(t, err)
+	_, err = second.Lock(ctx, "alert")
+	assert.ErrorIs(t, err, redisx.ErrNotObtained)
+
+	// A replica firing late does not run the job again after a fast run.
+	assert.NoError(t, lock.Unlock(ctx))
+	_, err = second.Lock(ctx, "alert")
+	assert.ErrorIs(t, err, redisx.ErrNotObtained)
+	assert.LessOrEqual(t, mr.TTL("test:{job:alert}"), 10*time.Second)
+	mr.FastForward(10 * time.Second)
+	lock, err = second.Lock(ctx, "alert")
+	assert.NoError(t, err)
+
+	// Without MinHold the lock is released when the run ends.
+	noHold := redisx.NewJobLocker(redisx.NewLocker(client, "test"), time.Minute, 0)
+	assert.NoError(t, lock.Unlock(ctx))
+	mr.FastForward(10 * time.Second)
+	lock, err = noHold.Lock(ctx, "alert")
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Unlock(ctx))
+	assert.False(t, mr.Exists("test:{job:alert}"))
+}
@@ -0,0 +1,15 @@
+package redisx
+
+import (
+	"context"
+	"research-apm/pkg/health"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// HealthCheck returns a readiness check that sends PING.
+func HealthCheck(client redis.Cmdable) health.CheckFunc {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
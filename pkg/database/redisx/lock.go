@@ -0,0 +1,149 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrNotObtained is returned by TryLock when the lock is held by another owner.
+	ErrNotObtained = errors.New("redisx: lock not obtained")
+	// ErrLockLost is returned by Refresh and Release when the lock expired
+	// and may have been obtained by another owner.
+	ErrLockLost = errors.New("redisx: lock lost")
+)
+
+// obtainScript sets the lock if it is free and returns the next fencing
+// token, or 0 when the lock is held.
+//
+//	KEYS[1] = lock key, KEYS[2] = fencing counter key
+//	ARGV[1] = owner token, ARGV[2] = TTL (ms)
+var obtainScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// refreshScript extends the lock TTL if it is still held by the owner.
+//
+//	KEYS[1] = lock key, ARGV[1] = owner token, ARGV[2] = TTL (ms)
+var refreshScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Locker obtains distributed locks stored in Redis.
+//
+// A lock is a best-effort mutual exclusion: it expires after its TTL even if
+// the owner is still working, e.g. during a long GC pause. Writes protected
+// by a lock should carry Lock.Fence, so the resource can reject a writer
+// with an older fencing token.
+type Locker struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewLocker creates a locker. Keys are written as "<prefix>:{<key>}"; the
+// hash tag keeps the lock and its fencing counter in the same slot when
+// running on Redis Cluster.
+func NewLocker(client redis.UniversalClient, prefix string) *Locker {
+	if prefix == "" {
+		prefix = "lock"
+	}
+	return &Locker{client: client, prefix: prefix}
+}
+
+// TryLock obtains the lock of key for ttl, or returns ErrNotObtained
+// when it is held.
+func (l *Locker) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	lock := &Lock{
+		client: l.client,
+		key:    fmt.Sprintf("%s:{%s}", l.prefix, key),
+		token:  newToken(),
+	}
+	start := time.Now()
+	fence, err := obtainScript.Run(ctx, l.client, []string{lock.key, lock.key + ":fence"}, lock.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrNotObtained
+	}
+	lock.fence = fence
+	lock.deadline = start.Add(ttl)
+	return lock, nil
+}
+
+// Lock waits until the lock of key is obtained for ttl, retrying every
+// retry, or until ctx is done.
+func (l *Locker) Lock(ctx context.Context, key string, ttl, retry time.Duration) (*Lock, error) {
+	ticker := time.NewTicker(retry)
+	defer ticker.Stop()
+	for {
+		lock, err := l.TryLock(ctx, key, ttl)
+		if err != ErrNotObtained {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Lock is an obtained lock.
+type Lock struct {
+	client   redis.UniversalClient
+	key      string
+	token    string
+	fence    int64
+	deadline time.Time
+}
+
+// Fence returns the fencing token of the lock. It increases every time
+// the lock of the key is obtained.
+func (l *Lock) Fence() int64 {
+	return l.fence
+}
+
+// Deadline returns when the lock expires at the latest. It is measured
+// from before the request that obtained or last refreshed the lock, so the
+// lock is not held past it whatever the latency of that request.
+func (l *Lock) Deadline() time.Time {
+	return l.deadline
+}
+
+// Refresh extends the lock to ttl from now, or returns ErrLockLost.
+func (l *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	start := time.Now()
+	ok, err := refreshScript.Run(ctx, l.client, []string{l.key}, l.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLockLost
+	}
+	l.deadline = start.Add(ttl)
+	return nil
+}
+
+// Release releases the lock, or returns ErrLockLost when it had expired.
+// A lock held by another owner is never released.
+func (l *Lock) Release(ctx context.Context) error {
+	n, err := unlockScript.Run(ctx, l.client, []string{l.key}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package redisx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"research-apm/pkg/database/redisx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTryLockContention verifies that a held lock is not obtained by
+// another owner and that fencing tokens only increase.
+func TestTryLockContention(t *testing.T) {
+	_, client := newMiniRedis(t)
+	locker := redisx.NewLocker(client, "test")
+	ctx := context.Background()
+
+	first, err := locker.TryLock(ctx, "job", time.Minute)
+	assert.NoError(t, err)
+	_, err = locker.TryLock(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, redisx.ErrNotObtained)
+	other, err := locker.TryLock(ctx, "other", time.Minute)
+	assert.NoError(t, err, "keys are locked independently")
+	assert.NoError(t, other.Release(ctx))
+
+	assert.NoError(t, first.Release(ctx))
+	second, err := locker.TryLock(ctx, "job", time.Minute)
+	assert.NoError(t, err)
+	assert.Greater(t, second.Fence(), first.Fence())
+	assert.WithinDuration(t, time.Now().Add(time.Minute), second.Deadline(), time.Second)
+}
+
+// TestLockExpired verifies that an owner whose lock expired can neither
+// refresh nor release the lock obtained by the next owner.
+func TestLockExpired(t *testing.T) {
+	mr, client := newMiniRedis(t)
+	locker := redisx.NewLocker(client, "test")
+	ctx := context.Background()
+
+	expired, err := locker.TryLock(ctx, "job", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test:{job}", "test:{job}:fence"}, mr.Keys(), "lock and fence share the hash tag")
+	mr.FastForward(time.Second)
+
+	current, err := locker.TryLock(ctx, "job", time.Minute)
+	assert.NoError(t, err)
+	assert.Greater(t, current.Fence(), expired.Fence())
+
+	assert.ErrorIs(t, expired.Refresh(ctx, time.Minute), redisx.ErrLockLost)
+	assert.ErrorIs(t, expired.Release(ctx), redisx.ErrLockLost)
+	_, err = locker.TryLock(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, redisx.ErrNotObtained, "still held by the current owner")
+
+	assert.NoError(t, current.Refresh(ctx, time.Minute))
+	assert.NoError(t, current.Release(ctx))
+}
+
+// TestLockWaits verifies that Lock obtains the lock once it is released
+// and gives up when ctx is done.
+func TestLockWaits(t *testing.T) {
+	_, client := newMiniRedis(t)
+	locker := redisx.NewLocker(client, "test")
+	ctx := context.Background()
+
+	held, err := locker.TryLock(ctx, "job", time.Minute)
+	assert.NoError(t, err)
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = locker.Lock(timeout, "job", time.Minute, 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		held.Release(ctx)
+	}()
+	lock, err := locker.Lock(ctx, "job", time.Minute, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Greater(t, lock.Fence(), held.Fence())
+}
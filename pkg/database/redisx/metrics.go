@@ -0,0 +1,37 @@
+package redisx
+
+import (
+	"research-apm/pkg/metrics"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// poolStater is implemented by redis.Client, redis.ClusterClient and redis.Ring.
+type poolStater interface {
+	PoolStats() *redis.PoolStats
+}
+
+// RegisterMetrics exposes the connection pool stats of client in
+// metrics.Default, labeled with client=name.
+func RegisterMetrics(name string, client poolStater) {
+	labels := metrics.Labels{"client": name}
+	reg := metrics.Default
+	reg.CounterFunc("redis_pool_hits", "Number of times a free connection was found in the pool.", labels, func() float64 {
+		return float64(client.PoolStats().Hits)
+	})
+	reg.CounterFunc("redis_pool_misses", "Number of times a free connection was not found in the pool.", labels, func() float64 {
+		return float64(client.PoolStats().Misses)
+	})
+	reg.CounterFunc("redis_pool_timeouts", "Number of times a wait for a connection timed out.", labels, func() float64 {
+		return float64(client.PoolStats().Timeouts)
+	})
+	reg.GaugeFunc("redis_pool_total_connections", "Number of connections in the pool.", labels, func() float64 {
+		return float64(client.PoolStats().TotalConns)
+	})
+	reg.GaugeFunc("redis_pool_idle_connections", "Number of idle connections in the pool.", labels, func() float64 {
+		return float64(client.PoolStats().IdleConns)
+	})
+	reg.CounterFunc("redis_pool_stale_connections", "Number of stale connections removed from the pool.", labels, func() float64 {
+		return float64(client.PoolStats().StaleConns)
+	})
+}
@@ -0,0 +1,66 @@
+package redisx
+
+import (
+	"context"
+	"fmt"
+	"research-apm/pkg/ratelimit"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// slidingWindowScript atomically checks and increments a sliding window counter.
+//
+//	KEYS[1] = previous window key, KEYS[2] = current window key
+//	ARGV[1] = limit, ARGV[2] = window (ms), ARGV[3] = elapsed time in the current window (ms)
+//
+// Returns {allowed, prev, cur} where cur includes the request when allowed.
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+local prev = tonumber(redis.call('GET', KEYS[1]) or '0')
+local cur = tonumber(redis.call('GET', KEYS[2]) or '0')
+if prev * (window - elapsed) / window + cur >= limit then
+	return {0, prev, cur}
+end
+cur = redis.call('INCR', KEYS[2])
+if cur == 1 then
+	redis.call('PEXPIRE', KEYS[2], window * 2)
+end
+return {1, prev, cur}
+`)
+
+// RateLimitStore is a ratelimit.Store backed by Redis, so limits are shared
+// by every replica. Counting runs in a Lua script and is therefore atomic.
+type RateLimitStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRateLimitStore creates a Redis backed rate limit store.
+// Keys are written as "<prefix>:{<key>}:<window index>"; the hash tag keeps
+// both windows of a key in the same slot when running on Redis Cluster.
+func NewRateLimitStore(client redis.Cmdable, prefix string) *RateLimitStore {
+	if prefix == "" {
+		prefix = "ratelimit"
+	}
+	return &RateLimitStore{client: client, prefix: prefix}
+}
+
+// Allow implements ratelimit.Store.
+func (s *RateLimitStore) Allow(ctx context.Context, key string, limit int, window time.Duration) (ratelimit.Result, error) {
+	index, elapsed := ratelimit.Window(time.Now(), window)
+	keys := []string{
+		fmt.Sprintf("%s:{%s}:%d", s.prefix, key, index-1),
+		fmt.Sprintf("%s:{%s}:%d", s.prefix, key, index),
+	}
+	vals, err := slidingWindowScript.Run(ctx, s.client, keys, limit, window.Milliseconds(), elapsed.Milliseconds()).Int64Slice()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	if len(vals) != 3 {
+		return ratelimit.Result{}, fmt.Errorf("unexpected rate limit script result: %v", vals)
+	}
+	return ratelimit.Evaluate(vals[0] == 1, int(vals[1]), int(vals[2]), limit, elapsed, window), nil
+}
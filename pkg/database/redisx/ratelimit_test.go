@@ -0,0 +1,39 @@
+package redisx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"research-apm/pkg/database/redisx"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRateLimitStore verifies the sliding window script and its key layout.
+func TestRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newMiniRedis(t)
+	store := redisx.NewRateLimitStore(client, "rl")
+
+	for i := 0; i < 3; i++ {
+		res, err := store.Allow(ctx, "10.0.0.1", 3, time.Hour)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+	res, err := store.Allow(ctx, "10.0.0.1", 3, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Greater(t, res.RetryAfter, time.Duration(0))
+
+	res, err = store.Allow(ctx, "10.0.0.2", 3, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed, "keys are limited independently")
+
+	for _, key := range mr.Keys() {
+		assert.Regexp(t, `^rl:\{10\.0\.0\.[12]\}:\d+$`, key)
+		assert.Greater(t, mr.TTL(key), time.Hour, "a window outlives the next one")
+	}
+}
@@ -0,0 +1,36 @@
+package redisx_test
+
+import (
+	"context"
+	"testing"
+
+	"research-apm/pkg/database/redisx"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewClientConfig verifies the configuration errors reported before connecting.
+func TestNewClientConfig(t *testing.T) {
+	ctx := context.Background()
+	_, err := redisx.NewClient(ctx, redisx.Config{})
+	assert.EqualError(t, err, "redis: Url or Addrs is required")
+
+	_, err = redisx.NewClient(ctx, redisx.Config{Mode: redisx.ModeSentinel, Addrs: []string{"sentinel:26379"}})
+	assert.EqualError(t, err, "redis: MasterName is required in sentinel mode")
+
+	_, err = redisx.NewClient(ctx, redisx.Config{Mode: "ring", Addrs: []string{"redis:6379"}})
+	assert.EqualError(t, err, `redis: unknown mode "ring"`)
+
+	_, err = redisx.NewClient(ctx, redisx.Config{Addrs: []string{"redis:6379"}, TLS: &redisx.TLSConfig{CAFile: "/does/not/exist"}})
+	assert.ErrorContains(t, err, "redis tls:")
+}
+
+// newMiniRedis starts an in-memory Redis stand-in and returns a client of it.
+func newMiniRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
@@ -0,0 +1,131 @@
+package redisx
+
+import (
+	"context"
+	"fmt"
+	"research-apm/pkg/cache"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	_ cache.Cache[int] = (*Cache[int])(nil)
+	_ cache.Cache[int] = (*Tiered[int])(nil)
+)
+
+// TieredConfig configures a Tiered cache. Zero values fall back to the
+// defaults documented on each field.
+type TieredConfig struct {
+	Name    string        // Metrics label of the L1 tier.
+	L1Size  int           // Maximum number of L1 entries (default 1000).
+	L1TTL   time.Duration // Upper bound of the L1 TTL, i.e. the staleness bound when an invalidation is lost (default 5s).
+	Channel string        // Invalidation channel (default "<l2 prefix>:invalidate").
+}
+
+// Tiered is a two-tier cache: an in-process LRU (L1) in front of a Redis
+// Cache (L2). Delete removes a key from L2 and publishes it on a Redis
+// pub/sub channel, so every replica drops it from its L1.
+//
+// Pub/sub is fire and forget: a replica disconnected from Redis misses
+// invalidations, so it purges its L1 when it resubscribes, and L1TTL bounds
+// the staleness in between.
+type Tiered[T any] struct {
+	l1      *cache.LRU[T]
+	l2      *Cache[T]
+	client  redis.UniversalClient
+	channel string
+	pubsub  *redis.PubSub
+}
+
+// NewTiered creates a two-tier cache in front of l2 and subscribes to the
+// invalidation channel until Close.
+func NewTiered[T any](client redis.UniversalClient, l2 *Cache[T], cfg TieredConfig) *Tiered[T] {
+	if cfg.L1TTL <= 0 {
+		cfg.L1TTL = 5 * time.Second
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = "invalidate"
+		if l2.cfg.Prefix != "" {
+			cfg.Channel = l2.cfg.Prefix + ":invalidate"
+		}
+	}
+	t := &Tiered[T]{
+		l1:      cache.NewLRU[T](cache.LRUConfig{Name: cfg.Name, Size: cfg.L1Size, TTL: cfg.L1TTL}),
+		l2:      l2,
+		client:  client,
+		channel: cfg.Channel,
+		pubsub:  client.Subscribe(context.Background(), cfg.Channel),
+	}
+	go t.listen()
+	return t
+}
+
+// listen drops the keys published on the channel from L1 until Close.
+func (t *Tiered[T]) listen() {
+	ctx := context.Background()
+	for {
+		msg, err := t.pubsub.Receive(ctx)
+		if err != nil {
+			if err == redis.ErrClosed {
+				return
+			}
+			// The connection is re-established by the next Receive.
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// (Re)subscribed: invalidations may have been missed meanwhile.
+			if msg.Kind == "subscribe" {
+				t.l1.Purge()
+			}
+		case *redis.Message:
+			t.l1.Delete(ctx, msg.Payload)
+		}
+	}
+}
+
+// GetOrLoad returns the value of key from L1, then L2, then loader.
+// Values from L2 or the loader are kept in L1 for at most TieredConfig.L1TTL.
+func (t *Tiered[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	return t.l1.GetOrLoad(ctx, key, ttl, func(ctx context.Context) (T, error) {
+		return t.l2.GetOrLoad(ctx, key, ttl, loader)
+	})
+}
+
+// Set stores value in L2 and invalidates key in every L1.
+func (t *Tiered[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := t.l2.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return t.invalidate(ctx, key)
+}
+
+// Delete removes keys from L2 and invalidates them in every L1.
+func (t *Tiered[T]) Delete(ctx context.Context, keys ...string) error {
+	if err := t.l2.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return t.invalidate(ctx, keys...)
+}
+
+func (t *Tiered[T]) invalidate(ctx context.Context, keys ...string) error {
+	t.l1.Delete(ctx, keys...)
+	for _, key := range keys {
+		if err := t.client.Publish(ctx, t.channel, key).Err(); err != nil {
+			return fmt.Errorf("redisx cache: publish invalidation: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// L1 returns the in-process tier, e.g. to read its stats.
+func (t *Tiered[T]) L1() *cache.LRU[T] {
+	return t.l1
+}
+
+// Close stops listening for invalidations.
+func (t *Tiered[T]) Close() error {
+	return t.pubsub.Close()
+}
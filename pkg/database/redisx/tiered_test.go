@@ -0,0 +1,44 @@
+package redisx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"research-apm/pkg/database/redisx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTieredInvalidation verifies that a Delete on one replica drops the
+// key from the L1 of the other replicas.
+func TestTieredInvalidation(t *testing.T) {
+	mr, client := newMiniRedis(t)
+	newReplica := func() *redisx.Tiered[profile] {
+		l2 := redisx.NewCache(client, redisx.CacheConfig[profile]{Prefix: "test"})
+		tiered := redisx.NewTiered(client, l2, redisx.TieredConfig{L1TTL: time.Minute})
+		t.Cleanup(func() { tiered.Close() })
+		return tiered
+	}
+	first, second := newReplica(), newReplica()
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub("test:invalidate")["test:invalidate"] == 2
+	}, time.Second, 5*time.Millisecond, "both replicas subscribed")
+
+	ctx := context.Background()
+	name := "v1"
+	loader := func(ctx context.Context) (profile, error) {
+		return profile{Name: name}, nil
+	}
+	v, err := second.GetOrLoad(ctx, "budi", time.Minute, loader)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", v.Name)
+
+	// Without invalidation the L1 of the second replica keeps v1.
+	name = "v2"
+	assert.NoError(t, first.Delete(ctx, "budi"))
+	assert.Eventually(t, func() bool {
+		v, err := second.GetOrLoad(ctx, "budi", time.Minute, loader)
+		return err == nil && v.Name == "v2"
+	}, time.Second, 10*time.Millisecond)
+}
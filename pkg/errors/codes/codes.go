@@ -9,6 +9,7 @@ const (
 	PermissionDenied Code = "PERMISSION_DENIED"
 	DataNotFound     Code = "DATA_NOT_FOUND"
 	Conflict         Code = "DATA_CONFLICT"
+	TooManyRequests  Code = "TOO_MANY_REQUESTS"
 	PathNotFound     Code = "PATH_NOT_FOUND"
 	MethodNotFound   Code = "METHOD_NOT_FOUND"
 	Internal         Code = "INTERNAL_ERROR"
@@ -32,6 +33,8 @@ func (c Code) HttpStatus() int {
 		return 405
 	case Conflict:
 		return 409
+	case TooManyRequests:
+		return 429
 	case Unavailable:
 		return 503
 	default:
@@ -0,0 +1,126 @@
+package certreload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader serves a certificate and client CA pool loaded from files and
+// reloads them when the files change.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	cert    atomic.Pointer[tls.Certificate]
+	pool    atomic.Pointer[x509.CertPool]
+	modTime time.Time
+}
+
+// New loads the certificate and, when caFile is set, the client CA pool.
+func New(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.modTime = modTime
+	return r, nil
+}
+
+func (r *Reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %s", err.Error())
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		data, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("load client CA: %s", err.Error())
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("load client CA: no certificate found in %s", r.caFile)
+		}
+	}
+	r.cert.Store(&cert)
+	r.pool.Store(pool)
+	return nil
+}
+
+// latestModTime returns the latest modification time of the files.
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile, r.caFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Watch checks the files every interval until stop is closed and reloads
+// them when one changed. A failed reload keeps serving the previous
+// certificate, e.g. while only the new certificate has been written.
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			modTime, err := r.latestModTime()
+			if err != nil || !modTime.After(r.modTime) {
+				continue
+			}
+			if err := r.load(); err != nil {
+				fmt.Println("[ERROR] tls: reload certificate:", err.Error())
+				continue
+			}
+			r.modTime = modTime
+			fmt.Println("[INFO] tls: certificate reloaded")
+		}
+	}
+}
+
+// TLSConfig returns a server configuration that always uses the latest
+// certificate and client CA pool. Client certificates are verified against
+// the pool when given, or required as well when requireClientCert is set.
+func (r *Reloader) TLSConfig(requireClientCert bool) *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.cert.Load(), nil
+		},
+	}
+	if r.caFile == "" {
+		return base
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = r.pool.Load()
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return cfg, nil
+	}
+	return base
+}
@@ -0,0 +1,23 @@
+package concurrency
+
+import (
+	"research-apm/pkg/bulkhead"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/ginx/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit rejects requests with a retryable 503 once the bulkhead
+// has no free slot and its queue is full or timed out.
+func Limit(b *bulkhead.Bulkhead) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := b.Acquire(c.Request.Context()); err != nil {
+			response.Abort(c, errors.Wrap(codes.Unavailable, "too many concurrent requests", err))
+			return
+		}
+		defer b.Release()
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package handlerspan
+
+import (
+	"regexp"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/ginx/response"
+	"research-apm/pkg/tracer"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// closureSuffix matches the ".funcN" suffix of handlers returned by a constructor.
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$`)
+
+// Span wraps the route handler in a span named after it,
+// e.g. "delivery.GetUser" for research-apm/services/api/internal/delivery.GetUser.
+func Span() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := tracer.StartSpan(c.Request.Context(), spanName(c.HandlerName()))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		// The error event is sent once by the response; the handler span
+		// only gets the outcome of the code it wrote.
+		if code, ok := response.Code(c); ok && code != codes.Success {
+			span.SetOutcome(&errors.AppError{Code: code})
+		}
+	}
+}
+
+// spanName shortens a fully qualified handler name to "package.Function".
+func spanName(handlerName string) string {
+	name := handlerName[strings.LastIndex(handlerName, "/")+1:]
+	return closureSuffix.ReplaceAllString(name, "")
+}
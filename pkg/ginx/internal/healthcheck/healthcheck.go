@@ -0,0 +1,28 @@
+package healthcheck
+
+import (
+	"net/http"
+	"research-apm/pkg/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Live serves the liveness report of reg.
+func Live(reg *health.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, reg.Live())
+	}
+}
+
+// Ready serves the readiness report of reg, with status 503 when it is down.
+// The errors of failing checks are only served when reg exposes them.
+func Ready(reg *health.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := reg.Ready(c.Request.Context())
+		status := http.StatusOK
+		if !report.Up() {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
@@ -0,0 +1,91 @@
+package httpmetrics
+
+import (
+	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/ginx/response"
+	"research-apm/pkg/metrics"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// red holds the rate, errors and duration metrics of the HTTP requests.
+type red struct {
+	requests *metrics.Counter
+	errors   *metrics.Counter
+	duration *metrics.Histogram
+	inFlight *metrics.Gauge
+}
+
+var (
+	mu         sync.Mutex
+	registered = map[*metrics.Registry]*red{}
+)
+
+// newRED registers the families once per registry, so several engines
+// can share a registry.
+func newRED(reg *metrics.Registry) *red {
+	mu.Lock()
+	defer mu.Unlock()
+	if m, ok := registered[reg]; ok {
+		return m
+	}
+	m := &red{
+		requests: reg.NewCounter("http_requests", "Number of HTTP requests.", "method", "route", "status"),
+		errors:   reg.NewCounter("http_request_errors", "Number of failed HTTP requests by application code.", "method", "route", "code"),
+		duration: reg.NewHistogram("http_request_duration_seconds", "Duration of HTTP requests.", nil, "method", "route"),
+		inFlight: reg.NewGauge("http_requests_in_flight", "Number of HTTP requests being served.", "method", "route"),
+	}
+	registered[reg] = m
+	return m
+}
+
+// Record records the RED metrics of every request except those to skipPath.
+func Record(reg *metrics.Registry, skipPath string) gin.HandlerFunc {
+	m := newRED(reg)
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == skipPath {
+			c.Next()
+			return
+		}
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+
+		m.inFlight.Inc(method, route)
+		start := time.Now()
+		c.Next()
+		m.inFlight.Dec(method, route)
+
+		status := c.Writer.Status()
+		m.requests.Inc(method, route, strconv.Itoa(status))
+		m.duration.Observe(time.Since(start).Seconds(), method, route)
+		if code := errorCode(c, status); code != "" {
+			m.errors.Inc(method, route, string(code))
+		}
+	}
+}
+
+// errorCode returns the code of a failed response, or "" on success.
+// Responses not written by the response package are mapped from the status.
+func errorCode(c *gin.Context, status int) codes.Code {
+	if code, ok := response.Code(c); ok {
+		if code == codes.Success {
+			return ""
+		}
+		return code
+	}
+	switch {
+	case status < 400:
+		return ""
+	case status == 404:
+		return codes.PathNotFound
+	case status == 405:
+		return codes.MethodNotFound
+	}
+	return codes.UnknownError
+}
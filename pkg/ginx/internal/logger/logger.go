@@ -5,29 +5,31 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"io"
+	"research-apm/pkg/ginx/internal/principal"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Logging struct {
-	TraceID           string         `json:"traceId"`
-	AppName           string         `json:"appName"`
-	Method            string         `json:"method"`
-	Path              string         `json:"path"`
-	ElapsedTime       int64          `json:"elapsedTime"`
-	ClientIP          string         `json:"clientIp"`
-	Site              string         `json:"site"`
-	Environment       string         `json:"environment"`
-	ApkVersion        string         `json:"apkVersion"`
-	DBApkVersion      string         `json:"dbApkVersion"`
-	RequestUser       map[string]any `json:"requestUser"`
-	RequestQuery      map[string]any `json:"requestQuery"`
-	RequestBody       map[string]any `json:"requestBody"`
-	ResponseCode      int            `json:"responseCode"`
-	ResponseBody      map[string]any `json:"responseBody"`
-	AdditionalContent map[string]any `json:"additionalContent"`
-	Timestamp         time.Time      `json:"timestamp"`
+	TraceID           string               `json:"traceId"`
+	AppName           string               `json:"appName"`
+	Method            string               `json:"method"`
+	Path              string               `json:"path"`
+	ElapsedTime       int64                `json:"elapsedTime"`
+	ClientIP          string               `json:"clientIp"`
+	Site              string               `json:"site"`
+	Environment       string               `json:"environment"`
+	ApkVersion        string               `json:"apkVersion"`
+	DBApkVersion      string               `json:"dbApkVersion"`
+	RequestUser       map[string]any       `json:"requestUser"`
+	Principal         *principal.Principal `json:"principal,omitempty"`
+	RequestQuery      map[string]any       `json:"requestQuery"`
+	RequestBody       map[string]any       `json:"requestBody"`
+	ResponseCode      int                  `json:"responseCode"`
+	ResponseBody      map[string]any       `json:"responseBody"`
+	AdditionalContent map[string]any       `json:"additionalContent"`
+	Timestamp         time.Time            `json:"timestamp"`
 }
 
 func NewLogger(
@@ -72,6 +74,12 @@ func NewLogger(
 			}
 		}
 
+		// Identity of the client certificate, if authenticated
+		var reqPrincipal *principal.Principal
+		if p, ok := principal.Get(ctx); ok {
+			reqPrincipal = &p
+		}
+
 		// Convert query params into map[string]any
 		var requestQuery map[string]any = nil
 		if rawQuery := ctx.Request.URL.Query(); len(rawQuery) > 0 {
@@ -96,6 +104,7 @@ func NewLogger(
 			ApkVersion:        appVersion,
 			DBApkVersion:      appDbVersion,
 			RequestUser:       reqUser,
+			Principal:         reqPrincipal,
 			RequestQuery:      requestQuery,
 			RequestBody:       requestBody,
 			ResponseCode:      ctx.Writer.Status(),
@@ -0,0 +1,72 @@
+package principal
+
+import (
+	"crypto/x509"
+	"fmt"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/ginx/response"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// key is the gin context key of the principal.
+const key = "ginx.principal"
+
+// Principal is the identity of a client authenticated by its certificate.
+type Principal struct {
+	Subject      string   `json:"subject"`
+	CommonName   string   `json:"commonName"`
+	Organization []string `json:"organization,omitempty"`
+	SerialNumber string   `json:"serialNumber"`
+}
+
+// FromCertificate sets the principal of requests with a verified client
+// certificate.
+func FromCertificate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tls := c.Request.TLS; tls != nil && len(tls.VerifiedChains) > 0 {
+			c.Set(key, New(tls.VerifiedChains[0][0]))
+		}
+		c.Next()
+	}
+}
+
+// New creates the principal of cert.
+func New(cert *x509.Certificate) Principal {
+	return Principal{
+		Subject:      cert.Subject.String(),
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		SerialNumber: cert.SerialNumber.String(),
+	}
+}
+
+// Get returns the principal of the request, if authenticated.
+func Get(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(key)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
+
+// Require rejects requests without a principal with 401, and requests whose
+// principal common name or subject is not in allowed with 403.
+// An empty allowed list accepts every authenticated principal.
+func Require(allowed []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := Get(c)
+		if !ok {
+			response.Abort(c, errors.New(codes.Unauthorized, "client certificate required", fmt.Errorf("no verified client certificate")))
+			return
+		}
+		if len(allowed) > 0 && !slices.Contains(allowed, p.CommonName) && !slices.Contains(allowed, p.Subject) {
+			response.Abort(c, errors.New(codes.PermissionDenied, "client certificate not allowed", fmt.Errorf("client %q is not allowed", p.Subject)))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,98 @@
+package throttle
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/ginx/response"
+	"research-apm/pkg/ratelimit"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc returns the key a request is counted under.
+type KeyFunc func(c *gin.Context) string
+
+// RateLimit rejects requests over limit per window with a retryable 429 response.
+func RateLimit(store ratelimit.Store, limit int, window time.Duration, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := store.Allow(c.Request.Context(), key(c), limit, window)
+		if err != nil {
+			// Fail open: an unavailable store must not take the API down with it.
+			fmt.Println("[ERROR] rate limit:", err.Error())
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(max(1, seconds(res.RetryAfter))))
+			response.Abort(c, &errors.AppError{
+				Code:        codes.TooManyRequests,
+				Message:     "too many requests, please try again later",
+				Errors:      fmt.Errorf("rate limit of %d requests per %s exceeded", limit, window),
+				IsRetryable: true,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds rounds a duration up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ByIP counts requests per client IP.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByHeader counts requests per value of the given header (e.g. the HMAC client ID),
+// falling back to the client IP when the header is missing.
+// The header is NOT checked here; put the rate limiter after the authentication
+// middleware if clients must not be able to pick their key.
+func ByHeader(name string) KeyFunc {
+	return func(c *gin.Context) string {
+		if v := c.GetHeader(name); v != "" {
+			return "client:" + v
+		}
+		return ByIP(c)
+	}
+}
+
+// ByJWTSubject counts requests per "sub" claim of the bearer token,
+// falling back to the client IP when there is no readable token.
+//
+// The token signature is NOT verified here; put the rate limiter after the
+// authentication middleware if clients must not be able to pick their key.
+func ByJWTSubject(c *gin.Context) string {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return ByIP(c)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ByIP(c)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ByIP(c)
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" {
+		return ByIP(c)
+	}
+	return "sub:" + claims.Subject
+}
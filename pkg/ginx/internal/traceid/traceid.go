@@ -2,6 +2,7 @@ package traceid
 
 import (
 	"fmt"
+	"research-apm/pkg/tracer"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oklog/ulid/v2"
@@ -15,6 +16,7 @@ func TraceID() gin.HandlerFunc {
 			c.Request.Header.Set("X-Trace-ID", traceID)
 		}
 		c.Writer.Header().Set("X-Trace-ID", traceID)
+		c.Request = c.Request.WithContext(tracer.ContextWithTraceID(c.Request.Context(), traceID))
 		c.Next()
 	}
 }
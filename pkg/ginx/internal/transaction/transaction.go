@@ -0,0 +1,67 @@
+package transaction
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.elastic.co/apm/module/apmhttp/v2"
+	"go.elastic.co/apm/v2"
+)
+
+// Elastic starts an Elastic APM transaction per request, like apmgin, but
+// keeps a result set by the handler (tracer.SetResult) instead of always
+// overwriting it with the HTTP status.
+func Elastic() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := apm.DefaultTracer()
+		if !t.Recording() {
+			c.Next()
+			return
+		}
+
+		name := apmhttp.UnknownRouteRequestName(c.Request)
+		if fullPath := c.FullPath(); fullPath != "" {
+			name = c.Request.Method + " " + fullPath
+		}
+		tx, body, req := apmhttp.StartTransactionWithBody(t, name, c.Request)
+		defer tx.End()
+		c.Request = req
+
+		defer func() {
+			if v := recover(); v != nil {
+				if !c.Writer.Written() {
+					c.AbortWithStatus(http.StatusInternalServerError)
+				} else {
+					c.Abort()
+				}
+				e := t.Recovered(v)
+				e.SetTransaction(tx)
+				setContext(&e.Context, c, body)
+				e.Send()
+			}
+			if tx.Result == "" {
+				tx.Result = apmhttp.StatusCodeResult(c.Writer.Status())
+			}
+			if tx.Sampled() {
+				setContext(&tx.Context, c, body)
+			}
+			for _, err := range c.Errors {
+				e := t.NewError(err.Err)
+				e.SetTransaction(tx)
+				setContext(&e.Context, c, body)
+				e.Handled = true
+				e.Send()
+			}
+			body.Discard()
+		}()
+		c.Next()
+	}
+}
+
+func setContext(ctx *apm.Context, c *gin.Context, body *apm.BodyCapturer) {
+	ctx.SetFramework("gin", gin.Version)
+	ctx.SetHTTPRequest(c.Request)
+	ctx.SetHTTPRequestBody(body)
+	ctx.SetHTTPStatusCode(c.Writer.Status())
+	ctx.SetHTTPResponseHeaders(c.Writer.Header())
+}
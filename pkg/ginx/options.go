@@ -7,15 +7,27 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"research-apm/pkg/bulkhead"
 	"research-apm/pkg/ginx/internal/auth"
+	"research-apm/pkg/ginx/internal/concurrency"
+	"research-apm/pkg/ginx/internal/handlerspan"
+	"research-apm/pkg/ginx/internal/healthcheck"
+	"research-apm/pkg/ginx/internal/httpmetrics"
 	"research-apm/pkg/ginx/internal/logger"
+	"research-apm/pkg/ginx/internal/principal"
+	"research-apm/pkg/ginx/internal/throttle"
 	"research-apm/pkg/ginx/internal/traceid"
+	"research-apm/pkg/ginx/internal/transaction"
+	"research-apm/pkg/health"
+	"research-apm/pkg/metrics"
+	"research-apm/pkg/ratelimit"
+	"research-apm/pkg/tracer"
 	"sync"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"go.elastic.co/apm/module/apmgin/v2"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // WithVerifyHMAC adds a middleware that verifies HMAC signatures
@@ -35,6 +47,36 @@ type LogConfig struct {
 	AppDBVersion string
 }
 
+// Principal is the identity of a client authenticated by its certificate.
+type Principal = principal.Principal
+
+// WithClientCert adds a middleware that makes the subject of a verified
+// client certificate (see TLSConfig.ClientCAFile) the principal of the
+// request. It is logged by WithLogFile and WithLogPushHttp and returned by
+// GetPrincipal. Register it before the logging middlewares.
+func WithClientCert() EngineOption {
+	return func(e *gin.Engine) {
+		e.Use(principal.FromCertificate())
+	}
+}
+
+// GetPrincipal returns the principal of the request set by WithClientCert.
+func GetPrincipal(c *gin.Context) (Principal, bool) {
+	return principal.Get(c)
+}
+
+// RequireClientCert rejects requests without a client certificate principal
+// with 401 UNAUTHORIZED, and principals whose common name or full subject
+// is not in allowed with 403 PERMISSION_DENIED. An empty allowed list
+// accepts every verified client. Requires WithClientCert.
+//
+// Example usage:
+//
+//	route.GET("/client-do", ginx.RequireClientCert("bank-a.internal"), handler)
+func RequireClientCert(allowed ...string) gin.HandlerFunc {
+	return principal.Require(allowed)
+}
+
 // WithLogFile adds a middleware that logs request/response information
 // and writes logs into the given file in JSON format.
 //
@@ -49,32 +91,12 @@ func WithLogFile(
 ) EngineOption {
 	encoder := json.NewEncoder(file)
 	logCh := make(chan logger.Logging, 100)
-
-	var wg sync.WaitGroup
-	wg.Add(1)
+	registerLogQueue("file", logCh)
 
 	// Background goroutine that encodes logs to the file
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case log, ok := <-logCh:
-				if !ok {
-					return // channel closed
-				}
-				encoder.Encode(log)
-			case <-ctx.Done():
-				return // shutdown signal
-			}
-		}
-	}()
-
-	// Shutdown hook: wait for all logs to be flushed before exit
-	go func() {
-		<-ctx.Done()
-		close(logCh)
-		wg.Wait()
-	}()
+	startLogWriter(ctx, logCh, func(log logger.Logging) {
+		encoder.Encode(log)
+	})
 
 	return func(e *gin.Engine) {
 		e.Use(logger.NewLogger(
@@ -88,6 +110,81 @@ func WithLogFile(
 	}
 }
 
+// logWriters tracks the background log writers so FlushLogs can wait for them.
+var logWriters struct {
+	wg   sync.WaitGroup
+	once sync.Once
+	stop chan struct{}
+}
+
+func init() {
+	logWriters.stop = make(chan struct{})
+}
+
+// startLogWriter writes the entries of logCh with write in the background
+// until ctx is done or FlushLogs is called, then writes the entries still
+// queued and returns. logCh is never closed, so a late request cannot panic.
+func startLogWriter(ctx context.Context, logCh chan logger.Logging, write func(logger.Logging)) {
+	logWriters.wg.Add(1)
+	go func() {
+		defer logWriters.wg.Done()
+		for {
+			select {
+			case log := <-logCh:
+				write(log)
+			case <-ctx.Done():
+				drainLogs(logCh, write)
+				return
+			case <-logWriters.stop:
+				drainLogs(logCh, write)
+				return
+			}
+		}
+	}()
+}
+
+func drainLogs(logCh chan logger.Logging, write func(logger.Logging)) {
+	for {
+		select {
+		case log := <-logCh:
+			write(log)
+		default:
+			return
+		}
+	}
+}
+
+// FlushLogs stops the log writers of WithLogFile and WithLogPushHttp after
+// they have written the queued entries, and waits for them until ctx is done.
+// Call it after the HTTP server has stopped and before closing the log file.
+func FlushLogs(ctx context.Context) error {
+	logWriters.once.Do(func() {
+		close(logWriters.stop)
+	})
+	done := make(chan struct{})
+	go func() {
+		logWriters.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flush logs: %s", ctx.Err().Error())
+	}
+}
+
+// registerLogQueue exposes the depth of a log channel as a metric.
+func registerLogQueue(sink string, logCh chan logger.Logging) {
+	labels := metrics.Labels{"sink": sink}
+	metrics.Default.GaugeFunc("ginx_log_queue_depth", "Number of log entries waiting to be written.", labels, func() float64 {
+		return float64(len(logCh))
+	})
+	metrics.Default.GaugeFunc("ginx_log_queue_capacity", "Capacity of the log queue.", labels, func() float64 {
+		return float64(cap(logCh))
+	})
+}
+
 // WithLogPushHttp adds a middleware that logs request/response information
 // and pushes logs to a remote HTTP endpoint in JSON format.
 //
@@ -103,57 +200,35 @@ func WithLogPushHttp(
 	config LogConfig,
 ) EngineOption {
 	logCh := make(chan logger.Logging, 100)
-
-	var wg sync.WaitGroup
-	wg.Add(1)
+	registerLogQueue("http", logCh)
+	client := tracer.NewHTTPClient(&http.Client{Timeout: 5 * time.Second})
 
 	// Background goroutine that pushes logs to the HTTP endpoint
-	go func() {
-		defer wg.Done()
-		client := &http.Client{Timeout: 5 * time.Second}
+	startLogWriter(ctx, logCh, func(log logger.Logging) {
+		data, err := json.Marshal(log)
+		if err != nil {
+			fmt.Println("[ERROR] log push http: failed to marshal:", err)
+			return
+		}
 
-		for {
-			select {
-			case log, ok := <-logCh:
-				if !ok {
-					return // channel closed
-				}
-				data, err := json.Marshal(log)
-				if err != nil {
-					fmt.Println("[ERROR] log push http: failed to marshal:", err)
-					continue
-				}
-
-				req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
-				if err != nil {
-					fmt.Println("[ERROR] log push http: failed to create request:", err)
-					continue
-				}
-
-				req.Header.Set("Content-Type", "application/json")
-				for k, v := range header {
-					req.Header.Set(k, v)
-				}
-
-				resp, err := client.Do(req)
-				if err != nil {
-					fmt.Println("[ERROR] log push http: failed to send:", err)
-					continue
-				}
-				resp.Body.Close()
+		req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), "POST", url, bytes.NewBuffer(data))
+		if err != nil {
+			fmt.Println("[ERROR] log push http: failed to create request:", err)
+			return
+		}
 
-			case <-ctx.Done():
-				return // shutdown signal
-			}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header.Set(k, v)
 		}
-	}()
 
-	// Shutdown hook: wait for all logs to be flushed before exit
-	go func() {
-		<-ctx.Done()
-		close(logCh)
-		wg.Wait()
-	}()
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Println("[ERROR] log push http: failed to send:", err)
+			return
+		}
+		resp.Body.Close()
+	})
 
 	return func(e *gin.Engine) {
 		e.Use(logger.NewLogger(
@@ -179,11 +254,51 @@ func WithTraceID() EngineOption {
 
 // WithElasticAPM adds Elastic APM middleware to the Gin engine.
 // It automatically instruments incoming HTTP requests for performance
-// monitoring and error tracking. Requires proper Elastic APM configuration
-// via environment variables (e.g., ELASTIC_APM_SERVER_URL, ELASTIC_APM_SERVICE_NAME).
+// monitoring and error tracking. The transaction result is the AppError
+// code set by the response package, or the HTTP status otherwise.
+// Requires the Elastic APM tracer to be configured with tracer.InitTracer.
 func WithElasticAPM() EngineOption {
 	return func(e *gin.Engine) {
-		e.Use(apmgin.Middleware(e))
+		e.Use(transaction.Elastic())
+	}
+}
+
+// WithTracing adds the request tracing middleware of the active tracer backend:
+// Elastic APM (same as WithElasticAPM) or OpenTelemetry (otelgin).
+// Each route handler also gets its own span named after the handler,
+// e.g. "delivery.GetUser".
+// tracer.InitTracer must be called before the engine is created.
+func WithTracing() EngineOption {
+	return func(e *gin.Engine) {
+		switch tracer.CurrentBackend() {
+		case tracer.BackendOtel:
+			e.Use(otelgin.Middleware(tracer.ServiceName()))
+		default:
+			e.Use(transaction.Elastic())
+		}
+		e.Use(handlerspan.Span())
+	}
+}
+
+// WithMetrics records per-route request counts, latency histograms,
+// in-flight gauges and error counts by codes.Code into metrics.Default,
+// and serves metrics.Default on GET /metrics in the OpenMetrics text format.
+// Register it before the other middlewares so rejected requests are counted.
+func WithMetrics() EngineOption {
+	return func(e *gin.Engine) {
+		e.Use(httpmetrics.Record(metrics.Default, "/metrics"))
+		e.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+	}
+}
+
+// WithHealth mounts GET /healthz (liveness) and GET /readyz (readiness with
+// the checks registered in health.Default). Readiness turns false once
+// health.Default.Shutdown is called. Register it before rate limiting and
+// authentication so probes are not rejected.
+func WithHealth() EngineOption {
+	return func(e *gin.Engine) {
+		e.GET("/healthz", healthcheck.Live(health.Default))
+		e.GET("/readyz", healthcheck.Ready(health.Default))
 	}
 }
 
@@ -201,3 +316,98 @@ func WithCors(config cors.Config) EngineOption {
 		e.Use(cors.New(config))
 	}
 }
+
+// WithBulkhead adds a middleware that caps the number of concurrent in-flight
+// requests of the whole engine. Requests that cannot get a slot within the
+// bulkhead queue timeout are rejected with a retryable 503 response.
+func WithBulkhead(b *bulkhead.Bulkhead) EngineOption {
+	return func(e *gin.Engine) {
+		e.Use(concurrency.Limit(b))
+	}
+}
+
+// Bulkhead returns the same middleware as WithBulkhead for a single route group,
+// so routes backed by a slow dependency cannot starve the others.
+//
+// Example usage:
+//
+//	user := engine.Group("/user", ginx.Bulkhead(bulkhead.New(bulkhead.Config{
+//	    Name:          "user",
+//	    MaxConcurrent: 50,
+//	    MaxQueue:      100,
+//	})))
+func Bulkhead(b *bulkhead.Bulkhead) gin.HandlerFunc {
+	return concurrency.Limit(b)
+}
+
+// RateLimitKeyFunc returns the key a request is rate limited under.
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitByIP limits requests per client IP.
+func RateLimitByIP() RateLimitKeyFunc {
+	return throttle.ByIP
+}
+
+// RateLimitByHeader limits requests per value of a header, e.g. the HMAC client ID.
+// Requests without the header are limited per client IP.
+// The header is NOT checked here; put the rate limiter after the authentication
+// middleware if clients must not be able to pick their key.
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	return RateLimitKeyFunc(throttle.ByHeader(name))
+}
+
+// RateLimitByJWTSubject limits requests per "sub" claim of the bearer token.
+// Requests without a readable token are limited per client IP.
+func RateLimitByJWTSubject() RateLimitKeyFunc {
+	return throttle.ByJWTSubject
+}
+
+// RateLimitConfig holds the rate limiting settings.
+type RateLimitConfig struct {
+	Limit   int              // Maximum requests per window for a single key.
+	Window  time.Duration    // Length of the sliding window.
+	Store   ratelimit.Store  // Counter store, defaults to an in-memory store.
+	KeyFunc RateLimitKeyFunc // Request key, defaults to RateLimitByIP.
+}
+
+// Validate reports a limit or window that would reject every request or
+// cannot be counted: the window must be at least a millisecond.
+func (config RateLimitConfig) Validate() error {
+	if config.Limit <= 0 {
+		return fmt.Errorf("ginx: rate limit must be positive, got %d", config.Limit)
+	}
+	if config.Window < time.Millisecond {
+		return fmt.Errorf("ginx: rate limit window must be at least 1ms, got %s", config.Window)
+	}
+	return nil
+}
+
+// WithRateLimit adds a sliding window rate limiting middleware.
+// It sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+// on every response, and rejects requests over the limit with a retryable
+// 429 TOO_MANY_REQUESTS response and a Retry-After header.
+//
+// Use redisx.NewRateLimitStore as Store to share the limits across replicas:
+//
+//	ginx.WithRateLimit(ginx.RateLimitConfig{
+//	    Limit:  100,
+//	    Window: time.Minute,
+//	    Store:  redisx.NewRateLimitStore(redisClient, "research_apm.ratelimit"),
+//	})
+//
+// It panics when config is invalid, like registering a duplicated route in
+// gin; call RateLimitConfig.Validate first to fail the startup with an error.
+func WithRateLimit(config RateLimitConfig) EngineOption {
+	if err := config.Validate(); err != nil {
+		panic(err)
+	}
+	if config.Store == nil {
+		config.Store = ratelimit.NewMemoryStore()
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = RateLimitByIP()
+	}
+	return func(e *gin.Engine) {
+		e.Use(throttle.RateLimit(config.Store, config.Limit, config.Window, throttle.KeyFunc(config.KeyFunc)))
+	}
+}
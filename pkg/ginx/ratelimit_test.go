@@ -0,0 +1,98 @@
+package ginx_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/ginx"
+	"research-apm/pkg/ginx/response"
+	"research-apm/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRateLimitConfigValidate verifies that a limit or window that would
+// reject every request or divide by zero fails the startup.
+func TestRateLimitConfigValidate(t *testing.T) {
+	assert.NoError(t, ginx.RateLimitConfig{Limit: 10, Window: time.Minute}.Validate())
+	assert.Error(t, ginx.RateLimitConfig{Limit: 0, Window: time.Minute}.Validate())
+	assert.Error(t, ginx.RateLimitConfig{Limit: 10, Window: 0}.Validate())
+	assert.Error(t, ginx.RateLimitConfig{Limit: 10, Window: time.Microsecond}.Validate())
+	assert.Panics(t, func() { ginx.WithRateLimit(ginx.RateLimitConfig{Limit: 10}) })
+}
+
+// failingStore is a rate limit store whose backend is down.
+type failingStore struct{}
+
+func (failingStore) Allow(context.Context, string, int, time.Duration) (ratelimit.Result, error) {
+	return ratelimit.Result{}, fmt.Errorf("store unavailable")
+}
+
+func rateLimitedEngine(store ratelimit.Store) *gin.Engine {
+	engine := ginx.NewEngine(ginx.WithRateLimit(ginx.RateLimitConfig{
+		Limit:   2,
+		Window:  time.Minute,
+		Store:   store,
+		KeyFunc: ginx.RateLimitByHeader("X-Client-Id"),
+	}))
+	engine.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	return engine
+}
+
+func get(engine *gin.Engine, client string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("X-Client-Id", client)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+// TestRateLimit verifies the RateLimit-* headers on allowed requests and the
+// retryable 429 response with Retry-After once a key is over its limit.
+func TestRateLimit(t *testing.T) {
+	engine := rateLimitedEngine(ratelimit.NewMemoryStore())
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		w := get(engine, "a")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, fmt.Sprint(remaining), w.Header().Get("RateLimit-Remaining"))
+		assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+		assert.Empty(t, w.Header().Get("Retry-After"))
+	}
+
+	w := get(engine, "a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.NotEqual(t, "0", w.Header().Get("Retry-After"))
+
+	var body response.Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, string(codes.TooManyRequests), body.Code)
+	assert.True(t, body.IsRetryable)
+
+	// Other keys keep their own budget.
+	assert.Equal(t, http.StatusOK, get(engine, "b").Code)
+}
+
+// TestRateLimitFailOpen verifies that requests pass when the store fails.
+func TestRateLimitFailOpen(t *testing.T) {
+	engine := rateLimitedEngine(failingStore{})
+
+	for i := 0; i < 3; i++ {
+		w := get(engine, "a")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "pong", w.Body.String())
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
@@ -1,6 +1,7 @@
 package response
 
 import (
+	"context"
 	"fmt"
 	"research-apm/pkg/errors"
 	"research-apm/pkg/errors/codes"
@@ -18,12 +19,27 @@ type Response struct {
 	IsRetryable bool    `json:"isRetryable"` // Indicates whether the error can be retried
 }
 
+// codeKey is the gin context key of the code of the written response.
+const codeKey = "ginx.response.code"
+
+// Code returns the code of the response written by New or Abort.
+func Code(ctx *gin.Context) (codes.Code, bool) {
+	v, ok := ctx.Get(codeKey)
+	if !ok {
+		return "", false
+	}
+	code, ok := v.(codes.Code)
+	return code, ok
+}
+
 // New sends a standard JSON response based on the result or the provided error.
 // If an error is present, it maps it to an error response using the custom error package.
 func New(ctx *gin.Context, result any, err error) {
 	if err := errors.FromError(err); err != nil {
 		merr := err.Error()
-		tracer.CaptureError(ctx.Request.Context(), err)
+		tracer.CaptureError(requestContext(ctx), err)
+		tracer.SetResult(requestContext(ctx), err)
+		ctx.Set(codeKey, err.Code)
 		ctx.JSON(err.Code.HttpStatus(), &Response{
 			Code:        string(err.Code),
 			Message:     err.Message,
@@ -35,6 +51,8 @@ func New(ctx *gin.Context, result any, err error) {
 	}
 
 	// Success response
+	tracer.SetResult(requestContext(ctx), nil)
+	ctx.Set(codeKey, codes.Success)
 	ctx.JSON(codes.Success.HttpStatus(), &Response{
 		Code:        string(codes.Success),
 		Message:     "success",
@@ -47,16 +65,27 @@ func New(ctx *gin.Context, result any, err error) {
 // Abort is the same as New, but also stops the middleware chain by calling AbortWithStatusJSON.
 // Use this when you want to return early and prevent further processing.
 func Abort(ctx *gin.Context, err error) {
-	merr := errors.New(codes.UnknownError, "request abort with unknown error", fmt.Errorf("unknown error"))
+	merr := errors.New(codes.UnknownError, "request abort with unknown error", fmt.Errorf("request abort with unknown error"))
 	if err := errors.FromError(err); err != nil {
 		merr = err
 	}
-	tracer.CaptureError(ctx.Request.Context(), merr)
+	tracer.CaptureError(requestContext(ctx), merr)
+	tracer.SetResult(requestContext(ctx), merr)
+	ctx.Set(codeKey, merr.Code)
 	ctx.AbortWithStatusJSON(merr.Code.HttpStatus(), &Response{
 		Code:        string(merr.Code),
 		Message:     merr.Error(),
 		Data:        nil,
 		Errors:      &merr.Message,
-		IsRetryable: false,
+		IsRetryable: merr.IsRetryable,
 	})
 }
+
+// requestContext returns the context of the underlying request,
+// or a background context when the gin context has no request (e.g. in tests).
+func requestContext(ctx *gin.Context) context.Context {
+	if ctx.Request == nil {
+		return context.Background()
+	}
+	return ctx.Request.Context()
+}
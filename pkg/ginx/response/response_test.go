@@ -108,3 +108,26 @@ func TestNewWithStandardError(t *testing.T) {
 	assert.Contains(t, w.Body.String(), `"code":"UNKNOWN_ERROR"`)
 	assert.Contains(t, w.Body.String(), `"errors":"standard error"`)
 }
+
+// TestAbortRetryable verifies that response.Abort keeps the retryable flag
+// of the error, e.g. for rate limited requests.
+func TestAbortRetryable(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+
+	response.Abort(ctx, &errors.AppError{
+		Code:        codes.TooManyRequests,
+		Message:     "too many requests",
+		Errors:      fmt.Errorf("rate limit exceeded"),
+		IsRetryable: true,
+	})
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{
+		"code": "TOO_MANY_REQUESTS",
+		"message": "rate limit exceeded",
+		"data": null,
+		"errors": "too many requests",
+		"isRetryable": true
+	}`, w.Body.String())
+}
@@ -0,0 +1,240 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"research-apm/pkg/ginx/internal/certreload"
+	"research-apm/pkg/health"
+	"research-apm/pkg/tracer"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServerConfig holds the HTTP server settings. Zero values fall back to
+// the defaults documented on each field.
+type ServerConfig struct {
+	Addr              string        // Listen address (default ":8080").
+	ReadHeaderTimeout time.Duration // Time to read the request headers (default 5s).
+	ReadTimeout       time.Duration // Time to read the whole request (default 30s).
+	WriteTimeout      time.Duration // Time to write the response (default 30s).
+	IdleTimeout       time.Duration // Keep-alive idle time (default 120s).
+	MaxHeaderBytes    int           // Maximum size of the request headers (default 1 MiB).
+	MaxBodyBytes      int64         // Maximum size of a request body (default 10 MiB), reading past it fails.
+
+	// PreStopDelay is how long the server keeps serving after readiness
+	// turns false, so load balancers stop routing to it first (default 5s).
+	PreStopDelay time.Duration
+	// DrainTimeout is how long in-flight requests may take to complete
+	// before the remaining connections are closed (default 20s).
+	DrainTimeout time.Duration
+	// Health is the registry whose readiness fails during shutdown
+	// (default health.Default).
+	Health *health.Registry
+	// TLS serves HTTPS when set.
+	TLS *TLSConfig
+}
+
+// TLSConfig holds the HTTPS settings. The files are checked every
+// ReloadInterval and reloaded when changed, so rotated certificates are
+// served without a restart.
+type TLSConfig struct {
+	CertFile string // PEM server certificate (chain).
+	KeyFile  string // PEM private key of the certificate.
+	// ClientCAFile enables mutual TLS: client certificates are verified
+	// against these PEM CAs, and their subject becomes the principal
+	// (see WithClientCert).
+	ClientCAFile string
+	// RequireClientCert rejects connections without a client certificate.
+	// Leave it off to keep the health probes reachable and use
+	// RequireClientCert on the routes instead.
+	RequireClientCert bool
+	ReloadInterval    time.Duration // How often the files are checked (default 30s).
+}
+
+func (c *ServerConfig) setDefaults() {
+	if c.Addr == "" {
+		c.Addr = ":8080"
+	}
+	if c.ReadHeaderTimeout <= 0 {
+		c.ReadHeaderTimeout = 5 * time.Second
+	}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = 30 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 30 * time.Second
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = 120 * time.Second
+	}
+	if c.MaxHeaderBytes <= 0 {
+		c.MaxHeaderBytes = 1 << 20
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = 10 << 20
+	}
+	if c.PreStopDelay < 0 {
+		c.PreStopDelay = 0
+	} else if c.PreStopDelay == 0 {
+		c.PreStopDelay = 5 * time.Second
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = 20 * time.Second
+	}
+	if c.Health == nil {
+		c.Health = health.Default
+	}
+	if c.TLS != nil && c.TLS.ReloadInterval <= 0 {
+		c.TLS.ReloadInterval = 30 * time.Second
+	}
+}
+
+// Server is an HTTP server with graceful shutdown: readiness fails first,
+// then in-flight requests drain, then the remaining connections are closed
+// and the queued logs and APM transactions are flushed.
+type Server struct {
+	cfg      ServerConfig
+	server   *http.Server
+	listener net.Listener
+	inFlight atomic.Int64
+	stop     chan struct{} // stops the certificate reload
+	stopOnce sync.Once
+}
+
+// NewServer creates a server for handler, usually the engine of NewEngine.
+// Set PreStopDelay to a negative value to disable the delay, e.g. in tests.
+func NewServer(handler http.Handler, cfg ServerConfig) *Server {
+	cfg.setDefaults()
+	s := &Server{cfg: cfg, stop: make(chan struct{})}
+	s.server = &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           http.MaxBytesHandler(s.track(handler), cfg.MaxBodyBytes),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	return s
+}
+
+// track counts the requests being served.
+func (s *Server) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of requests being served.
+func (s *Server) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Addr returns the listen address, with the actual port once Listen returned.
+func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.server.Addr
+}
+
+// Listen loads the TLS certificates, if any, and binds the listen address,
+// so invalid certificates and a busy port are reported before serving.
+func (s *Server) Listen() error {
+	if tlsCfg := s.cfg.TLS; tlsCfg != nil {
+		reloader, err := certreload.New(tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("tls: %s", err.Error())
+		}
+		s.server.TLSConfig = reloader.TLSConfig(tlsCfg.RequireClientCert)
+		go reloader.Watch(tlsCfg.ReloadInterval, s.stop)
+	}
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		s.stopReload()
+		return err
+	}
+	s.listener = ln
+	return nil
+}
+
+func (s *Server) stopReload() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Serve serves requests until Shutdown, listening first if Listen was not
+// called. It returns nil once the server is shut down.
+func (s *Server) Serve() error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+	var err error
+	if s.server.TLSConfig != nil {
+		// The certificates come from TLSConfig.GetCertificate.
+		err = s.server.ServeTLS(s.listener, "", "")
+	} else {
+		err = s.server.Serve(s.listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops the server gracefully:
+//
+//  1. readiness turns false and the server keeps serving for PreStopDelay;
+//  2. new connections are refused and in-flight requests get DrainTimeout
+//     to complete, with the remaining count reported every second;
+//  3. connections still open are closed;
+//  4. the queued request logs and APM transactions are flushed.
+//
+// Every step is bounded by ctx as well.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.cfg.Health.Shutdown()
+	fmt.Println("[INFO] http server: readiness down,", s.InFlight(), "requests in flight")
+	select {
+	case <-time.After(s.cfg.PreStopDelay):
+	case <-ctx.Done():
+	}
+
+	drainCtx, cancel := context.WithTimeout(ctx, s.cfg.DrainTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.server.Shutdown(drainCtx)
+	}()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var err error
+drain:
+	for {
+		select {
+		case err = <-done:
+			break drain
+		case <-ticker.C:
+			fmt.Println("[INFO] http server: draining,", s.InFlight(), "requests in flight")
+		}
+	}
+	if err != nil {
+		fmt.Println("[WARN] http server: drain timed out, closing", s.InFlight(), "requests in flight")
+		err = errors.Join(fmt.Errorf("drain: %s", err.Error()), s.server.Close())
+	}
+
+	s.stopReload()
+
+	// The requests have ended, so their logs are queued and their
+	// transactions ended.
+	return errors.Join(err, FlushLogs(ctx), tracer.Flush(ctx))
+}
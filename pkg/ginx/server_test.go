@@ -0,0 +1,71 @@
+package ginx_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"research-apm/pkg/ginx"
+	"research-apm/pkg/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestServerShutdownDrains verifies that an in-flight request completes
+// during shutdown and that readiness fails before the server stops.
+func TestServerShutdownDrains(t *testing.T) {
+	reg := health.NewRegistry(health.Config{})
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	})
+	server := ginx.NewServer(mux, ginx.ServerConfig{
+		Addr:         "127.0.0.1:0",
+		MaxBodyBytes: 8,
+		PreStopDelay: -1,
+		DrainTimeout: 2 * time.Second,
+		Health:       reg,
+	})
+	assert.NoError(t, server.Listen())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve()
+	}()
+	url := "http://" + server.Addr()
+
+	resp, err := http.Post(url+"/upload", "text/plain", strings.NewReader("more than eight bytes"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	resp.Body.Close()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if !assert.NoError(t, err) {
+			body <- ""
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		body <- string(data)
+	}()
+	<-started
+	assert.Equal(t, int64(1), server.InFlight())
+
+	assert.NoError(t, server.Shutdown(context.Background()))
+	assert.False(t, reg.Ready(context.Background()).Up())
+	assert.Equal(t, "done", <-body)
+	assert.NoError(t, <-serveErr)
+	assert.Equal(t, int64(0), server.InFlight())
+}
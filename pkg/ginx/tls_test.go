@@ -0,0 +1,130 @@
+package ginx_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"research-apm/pkg/ginx"
+	"research-apm/pkg/health"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issue creates a certificate for cn signed by parent, or self-signed
+// when parent is nil.
+func issue(t *testing.T, cn string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"Bank"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func writePEM(t *testing.T, path string, cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+	if key != nil {
+		der, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path+".key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+	}
+}
+
+// TestServerMutualTLS verifies the client certificate principal, the route
+// authorization and the certificate reload.
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := issue(t, "Test CA", 1, nil, nil)
+	serverCert, serverKey := issue(t, "localhost", 2, ca, caKey)
+	clientCert, clientKey := issue(t, "bank-a.internal", 3, ca, caKey)
+	writePEM(t, filepath.Join(dir, "ca.pem"), ca, nil)
+	writePEM(t, filepath.Join(dir, "server.pem"), serverCert, serverKey)
+
+	engine := ginx.NewEngine(ginx.WithClientCert())
+	engine.GET("/client-do", ginx.RequireClientCert("bank-a.internal"), func(c *gin.Context) {
+		p, _ := ginx.GetPrincipal(c)
+		c.String(http.StatusOK, p.CommonName)
+	})
+	server := ginx.NewServer(engine, ginx.ServerConfig{
+		Addr:         "127.0.0.1:0",
+		PreStopDelay: -1,
+		Health:       health.NewRegistry(health.Config{}),
+		TLS: &ginx.TLSConfig{
+			CertFile:       filepath.Join(dir, "server.pem"),
+			KeyFile:        filepath.Join(dir, "server.pem.key"),
+			ClientCAFile:   filepath.Join(dir, "ca.pem"),
+			ReloadInterval: 20 * time.Millisecond,
+		},
+	})
+	require.NoError(t, server.Listen())
+	go server.Serve()
+	defer server.Shutdown(context.Background())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			ServerName:   "localhost",
+			Certificates: certs,
+		}}}
+	}
+	url := "https://" + server.Addr() + "/client-do"
+
+	resp, err := newClient().Get(url)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	client := newClient(tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey})
+	resp, err = client.Get(url)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, serverCert.SerialNumber, resp.TLS.PeerCertificates[0].SerialNumber)
+	resp.Body.Close()
+
+	// Rotate the server certificate; a new connection gets the new one.
+	rotated, rotatedKey := issue(t, "localhost", 4, ca, caKey)
+	future := time.Now().Add(time.Minute)
+	writePEM(t, filepath.Join(dir, "server.pem"), rotated, rotatedKey)
+	os.Chtimes(filepath.Join(dir, "server.pem"), future, future)
+	os.Chtimes(filepath.Join(dir, "server.pem.key"), future, future)
+	assert.Eventually(t, func() bool {
+		client.CloseIdleConnections()
+		resp, err := client.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Cmp(rotated.SerialNumber) == 0
+	}, 2*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,183 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status of a check or of the whole report.
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+// CheckFunc reports whether a dependency is usable. It must honor ctx,
+// which carries the per-check timeout.
+type CheckFunc func(ctx context.Context) error
+
+// Config configures a Registry. Zero values fall back to the defaults
+// documented on each field.
+type Config struct {
+	Timeout  time.Duration // Timeout of a single check (default 2s).
+	CacheTTL time.Duration // How long a readiness report is reused (default 5s).
+	// ExposeErrors includes the error of a failing check in the reports.
+	// Errors carry driver messages with hosts and users, so they are only
+	// logged by default; enable it on registries served to trusted callers.
+	ExposeErrors bool
+}
+
+// Default is the registry the clients register their checks in and that
+// ginx.WithHealth serves.
+var Default = NewRegistry(Config{})
+
+// CheckResult is the outcome of a single check.
+// Error is only set when Config.ExposeErrors is enabled.
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the JSON breakdown served by the health endpoints.
+type Report struct {
+	Status  string                 `json:"status"`
+	Checks  map[string]CheckResult `json:"checks,omitempty"`
+	Checked time.Time              `json:"checkedAt"`
+}
+
+// Up reports whether every check passed.
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Registry runs the registered checks for the readiness endpoint.
+type Registry struct {
+	cfg Config
+
+	mu     sync.Mutex
+	checks []check
+	cached *Report
+
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry(cfg Config) *Registry {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = 5 * time.Second
+	}
+	return &Registry{cfg: cfg}
+}
+
+// Register adds a readiness check. Registering a name twice replaces the check.
+func (r *Registry) Register(name string, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range r.checks {
+		if c.name == name {
+			r.checks[i].fn = fn
+			r.cached = nil
+			return
+		}
+	}
+	r.checks = append(r.checks, check{name: name, fn: fn})
+	r.cached = nil
+}
+
+// Shutdown marks the service as shutting down, so readiness turns false
+// and load balancers stop routing new requests while in-flight ones drain.
+func (r *Registry) Shutdown() {
+	r.shuttingDown.Store(true)
+}
+
+// Live reports that the process is running. It does not check dependencies,
+// so a failing database does not get the process restarted.
+func (r *Registry) Live() Report {
+	return Report{Status: StatusUp, Checked: time.Now()}
+}
+
+// Ready runs every check concurrently, each with its own timeout, and
+// reports DOWN if any of them fails or the service is shutting down.
+// Reports are cached for Config.CacheTTL to protect the dependencies
+// from aggressive probes.
+func (r *Registry) Ready(ctx context.Context) Report {
+	if r.shuttingDown.Load() {
+		return Report{Status: StatusDown, Checks: map[string]CheckResult{
+			"shutdown": {Status: StatusDown, Error: "service is shutting down"},
+		}, Checked: time.Now()}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cached != nil && time.Since(r.cached.Checked) < r.cfg.CacheTTL {
+		return *r.cached
+	}
+
+	results := make([]CheckResult, len(r.checks))
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(r.checks)), Checked: time.Now()}
+	for i, c := range r.checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	r.cached = &report
+	return report
+}
+
+// run executes a single check with the configured timeout.
+// The caller's cancellation is ignored so that an aborted probe does not
+// get a DOWN result cached.
+func (r *Registry) run(ctx context.Context, c check) CheckResult {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				errCh <- fmt.Errorf("check panicked: %v", v)
+			}
+		}()
+		errCh <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		// The check ignored its context; do not wait for it.
+		err = fmt.Errorf("check timed out after %s", r.cfg.Timeout)
+	}
+	result := CheckResult{Status: StatusUp, Duration: time.Since(start).String()}
+	if err != nil {
+		fmt.Println("[WARN] health check", c.name, "failed:", err.Error())
+		result.Status = StatusDown
+		if r.cfg.ExposeErrors {
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
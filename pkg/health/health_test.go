@@ -0,0 +1,61 @@
+package health_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"research-apm/pkg/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReady verifies the breakdown, per-check timeout, caching and shutdown.
+func TestReady(t *testing.T) {
+	reg := health.NewRegistry(health.Config{Timeout: 50 * time.Millisecond, CacheTTL: time.Minute, ExposeErrors: true})
+	calls := 0
+	reg.Register("mongodb", func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	reg.Register("redis", func(ctx context.Context) error {
+		return fmt.Errorf("dial tcp: connection refused")
+	})
+	reg.Register("sqlserver", func(ctx context.Context) error {
+		time.Sleep(time.Second) // ignores ctx
+		return nil
+	})
+
+	start := time.Now()
+	report := reg.Ready(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.False(t, report.Up())
+	assert.Equal(t, health.StatusUp, report.Checks["mongodb"].Status)
+	assert.Equal(t, "dial tcp: connection refused", report.Checks["redis"].Error)
+	assert.Contains(t, report.Checks["sqlserver"].Error, "timed out")
+
+	reg.Ready(context.Background())
+	assert.Equal(t, 1, calls)
+
+	reg.Shutdown()
+	report = reg.Ready(context.Background())
+	assert.False(t, report.Up())
+	assert.Contains(t, report.Checks, "shutdown")
+	assert.True(t, reg.Live().Up())
+}
+
+// TestReadyHidesErrors verifies that the error of a failing check is left
+// out of the report unless the registry exposes errors.
+func TestReadyHidesErrors(t *testing.T) {
+	reg := health.NewRegistry(health.Config{})
+	reg.Register("postgres", func(ctx context.Context) error {
+		return fmt.Errorf("failed to connect to `host=db.internal user=app`: password authentication failed")
+	})
+
+	report := reg.Ready(context.Background())
+	assert.False(t, report.Up())
+	assert.Equal(t, health.StatusDown, report.Checks["postgres"].Status)
+	assert.Empty(t, report.Checks["postgres"].Error)
+	assert.NotEmpty(t, report.Checks["postgres"].Duration)
+}
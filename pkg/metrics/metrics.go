@@ -0,0 +1,391 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the OpenMetrics text format.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// DefaultBuckets are the latency histogram buckets in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the registry used by the instrumented packages (ginx, gormx,
+// mongox, redisx) and served by ginx.WithMetrics.
+var Default = NewRegistry()
+
+// Labels are the label pairs of a function metric.
+type Labels map[string]string
+
+// metricType is the OpenMetrics type of a family.
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// sample is a single line of the exposition.
+type sample struct {
+	suffix string // e.g. "_total", "_bucket"
+	labels []labelPair
+	value  float64
+}
+
+type labelPair struct {
+	name, value string
+}
+
+// family is a set of metrics sharing a name, type and help text.
+type family struct {
+	name    string
+	help    string
+	typ     metricType
+	collect []func() []sample
+}
+
+// Registry holds metric families and writes them in the OpenMetrics text format.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// register adds collect to the family name, creating it if needed.
+// It panics when the name is reused with a different type, like
+// registering a duplicated route in gin.
+func (r *Registry) register(name, help string, typ metricType, collect func() []sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ}
+		r.families[name] = f
+	}
+	if f.typ != typ {
+		panic(fmt.Sprintf("metrics: %s registered as %s and %s", name, f.typ, typ))
+	}
+	f.collect = append(f.collect, collect)
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at scrape time.
+func (r *Registry) GaugeFunc(name, help string, labels Labels, fn func() float64) {
+	pairs := sortedPairs(labels)
+	r.register(name, help, typeGauge, func() []sample {
+		return []sample{{labels: pairs, value: fn()}}
+	})
+}
+
+// CounterFunc registers a counter whose value is read from fn at scrape time.
+// fn must be monotonically increasing.
+func (r *Registry) CounterFunc(name, help string, labels Labels, fn func() float64) {
+	pairs := sortedPairs(labels)
+	r.register(name, help, typeCounter, func() []sample {
+		return []sample{{suffix: "_total", labels: pairs, value: fn()}}
+	})
+}
+
+// NewCounter registers a counter family with the given label names.
+// name must not end with "_total", the suffix is added on output.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{vec: newVec(labelNames)}
+	r.register(name, help, typeCounter, func() []sample {
+		return c.vec.samples("_total")
+	})
+	return c
+}
+
+// NewGauge registers a gauge family with the given label names.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{vec: newVec(labelNames)}
+	r.register(name, help, typeGauge, func() []sample {
+		return g.vec.samples("")
+	})
+	return g
+}
+
+// NewHistogram registers a histogram family with the given upper bounds,
+// DefaultBuckets when nil, and label names.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{buckets: buckets, labelNames: labelNames, series: map[string]*histogramSeries{}}
+	r.register(name, help, typeHistogram, h.samples)
+	return h
+}
+
+// WriteTo writes all families in the OpenMetrics text format, ending with "# EOF".
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var b strings.Builder
+	for _, f := range families {
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.typ)
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		for _, collect := range f.collect {
+			for _, s := range collect() {
+				b.WriteString(f.name)
+				b.WriteString(s.suffix)
+				writeLabels(&b, s.labels)
+				b.WriteByte(' ')
+				b.WriteString(formatValue(s.value))
+				b.WriteByte('\n')
+			}
+		}
+	}
+	b.WriteString("# EOF\n")
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves the registry in the OpenMetrics text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		if _, err := r.WriteTo(w); err != nil {
+			fmt.Println("[ERROR] metrics: write", err.Error())
+		}
+	})
+}
+
+// Counter is a monotonically increasing value per label set.
+type Counter struct {
+	vec *vec
+}
+
+// Inc adds one to the counter of the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter of the label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.vec.add(v, labelValues)
+}
+
+// Gauge is a value that can go up and down per label set.
+type Gauge struct {
+	vec *vec
+}
+
+// Inc adds one to the gauge of the label values.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.vec.add(1, labelValues)
+}
+
+// Dec subtracts one from the gauge of the label values.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.vec.add(-1, labelValues)
+}
+
+// Set sets the gauge of the label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.vec.set(v, labelValues)
+}
+
+// vec stores one value per label set.
+type vec struct {
+	mu         sync.Mutex
+	labelNames []string
+	values     map[string]*vecValue
+}
+
+type vecValue struct {
+	labels []labelPair
+	value  float64
+}
+
+func newVec(labelNames []string) *vec {
+	return &vec{labelNames: labelNames, values: map[string]*vecValue{}}
+}
+
+func (v *vec) get(labelValues []string) *vecValue {
+	key := strings.Join(labelValues, "\xff")
+	val, ok := v.values[key]
+	if !ok {
+		val = &vecValue{labels: pairs(v.labelNames, labelValues)}
+		v.values[key] = val
+	}
+	return val
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.get(labelValues).value += delta
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.get(labelValues).value = value
+}
+
+func (v *vec) samples(suffix string) []sample {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	out := make([]sample, 0, len(v.values))
+	for _, val := range v.values {
+		out = append(out, sample{suffix: suffix, labels: val.labels, value: val.value})
+	}
+	sortSamples(out)
+	return out
+}
+
+// Histogram counts observations into cumulative buckets per label set.
+type Histogram struct {
+	mu         sync.Mutex
+	buckets    []float64
+	labelNames []string
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []labelPair
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Observe records v for the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labels: pairs(h.labelNames, labelValues), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) samples() []sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out []sample
+	for _, k := range keys {
+		s := h.series[k]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			out = append(out, sample{
+				suffix: "_bucket",
+				labels: append(append([]labelPair{}, s.labels...), labelPair{"le", formatValue(upper)}),
+				value:  float64(cumulative),
+			})
+		}
+		out = append(out,
+			sample{suffix: "_bucket", labels: append(append([]labelPair{}, s.labels...), labelPair{"le", "+Inf"}), value: float64(s.count)},
+			sample{suffix: "_count", labels: s.labels, value: float64(s.count)},
+			sample{suffix: "_sum", labels: s.labels, value: s.sum},
+		)
+	}
+	return out
+}
+
+// pairs zips label names and values. Missing values are empty.
+func pairs(names, values []string) []labelPair {
+	out := make([]labelPair, len(names))
+	for i, name := range names {
+		out[i].name = name
+		if i < len(values) {
+			out[i].value = values[i]
+		}
+	}
+	return out
+}
+
+func sortedPairs(labels Labels) []labelPair {
+	out := make([]labelPair, 0, len(labels))
+	for k, v := range labels {
+		out = append(out, labelPair{k, v})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortSamples(samples []sample) {
+	key := func(s sample) string {
+		var b strings.Builder
+		for _, l := range s.labels {
+			b.WriteString(l.value)
+			b.WriteByte(0xff)
+		}
+		return b.String()
+	}
+	sort.Slice(samples, func(i, j int) bool { return key(samples[i]) < key(samples[j]) })
+}
+
+func writeLabels(b *strings.Builder, labels []labelPair) {
+	if len(labels) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(l.value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
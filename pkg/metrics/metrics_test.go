@@ -0,0 +1,47 @@
+package metrics_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"research-apm/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRegistryOpenMetrics verifies the text exposition of every metric type.
+func TestRegistryOpenMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	requests := reg.NewCounter("http_requests", "Number of HTTP requests.", "route", "status")
+	latency := reg.NewHistogram("http_request_duration_seconds", "Duration of HTTP requests.", []float64{0.1, 1}, "route")
+	reg.GaugeFunc("ginx_log_queue_depth", "Number of log entries waiting to be written.", metrics.Labels{"sink": "file"}, func() float64 {
+		return 3
+	})
+
+	requests.Inc("/user", "200")
+	requests.Add(2, "/user", "500")
+	latency.Observe(0.05, "/user")
+	latency.Observe(0.5, "/user")
+	latency.Observe(3, "/user")
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, metrics.ContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, `# TYPE ginx_log_queue_depth gauge
+# HELP ginx_log_queue_depth Number of log entries waiting to be written.
+ginx_log_queue_depth{sink="file"} 3
+# TYPE http_request_duration_seconds histogram
+# HELP http_request_duration_seconds Duration of HTTP requests.
+http_request_duration_seconds_bucket{route="/user",le="0.1"} 1
+http_request_duration_seconds_bucket{route="/user",le="1"} 2
+http_request_duration_seconds_bucket{route="/user",le="+Inf"} 3
+http_request_duration_seconds_count{route="/user"} 3
+http_request_duration_seconds_sum{route="/user"} 3.55
+# TYPE http_requests counter
+# HELP http_requests Number of HTTP requests.
+http_requests_total{route="/user",status="200"} 1
+http_requests_total{route="/user",status="500"} 2
+# EOF
+`, rec.Body.String())
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired keys are removed from memory.
+const sweepInterval = time.Minute
+
+// counter holds the fixed window counters of a single key.
+type counter struct {
+	index   int64
+	prev    int
+	cur     int
+	expires time.Time // after this the counters can no longer affect a check
+}
+
+// MemoryStore is an in-process Store. Limits are enforced per replica,
+// use the Redis store (redisx.NewRateLimitStore) to share them across replicas.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	nextSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*counter)}
+}
+
+// Allow implements Store.
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	now := time.Now()
+	index, elapsed := Window(now, window)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	c, ok := s.counters[key]
+	if !ok {
+		c = &counter{index: index}
+		s.counters[key] = c
+	}
+	switch {
+	case c.index == index-1:
+		c.index, c.prev, c.cur = index, c.cur, 0
+	case c.index < index-1:
+		c.index, c.prev, c.cur = index, 0, 0
+	}
+
+	allowed := Estimate(c.prev, c.cur, elapsed, window) < float64(limit)
+	if allowed {
+		c.cur++
+	}
+	c.expires = now.Add(2 * window)
+	return Evaluate(allowed, c.prev, c.cur, limit, elapsed, window), nil
+}
+
+// sweep removes expired keys, at most once per sweepInterval.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(sweepInterval)
+	for key, c := range s.counters {
+		if now.After(c.expires) {
+			delete(s.counters, key)
+		}
+	}
+}
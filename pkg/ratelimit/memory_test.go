@@ -0,0 +1,41 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"research-apm/pkg/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMemoryStoreAllow verifies that requests over the limit are rejected
+// with a retry delay, while other keys are counted separately.
+func TestMemoryStoreAllow(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	ctx := context.Background()
+
+	for i := range 3 {
+		res, err := store.Allow(ctx, "ip:10.0.0.1", 3, time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+
+	res, err := store.Allow(ctx, "ip:10.0.0.1", 3, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Greater(t, res.RetryAfter, time.Duration(0))
+
+	res, err = store.Allow(ctx, "ip:10.0.0.2", 3, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+// TestEstimate verifies the weighting of the previous window.
+func TestEstimate(t *testing.T) {
+	assert.Equal(t, 15.0, ratelimit.Estimate(10, 10, 30*time.Second, time.Minute))
+	assert.Equal(t, 10.0, ratelimit.Estimate(10, 0, 0, time.Minute))
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result is the outcome of a single rate limit check.
+type Result struct {
+	Allowed    bool          // whether the request may proceed
+	Limit      int           // maximum requests per window
+	Remaining  int           // requests left in the current window
+	Reset      time.Duration // time until the current window ends
+	RetryAfter time.Duration // time to wait before retrying, only set when not allowed
+}
+
+// Store counts requests per key using a sliding window.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Allow records a request for key if it fits in the limit and reports the result.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
+
+// Window identifies the fixed window that contains now and how far into it now is.
+func Window(now time.Time, window time.Duration) (index int64, elapsed time.Duration) {
+	ms := now.UnixMilli()
+	size := window.Milliseconds()
+	index = ms / size
+	return index, time.Duration(ms-index*size) * time.Millisecond
+}
+
+// Estimate approximates the number of requests in the sliding window ending now
+// by weighting the previous fixed window with the part of it still covered.
+func Estimate(prev, cur int, elapsed, window time.Duration) float64 {
+	weight := float64(window-elapsed) / float64(window)
+	return float64(prev)*weight + float64(cur)
+}
+
+// Evaluate builds the Result of a check from the window counters.
+// cur must already include the current request when allowed is true.
+func Evaluate(allowed bool, prev, cur, limit int, elapsed, window time.Duration) Result {
+	estimate := Estimate(prev, cur, elapsed, window)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(0, limit-int(estimate+0.999)),
+		Reset:     window - elapsed,
+	}
+	if !allowed {
+		res.RetryAfter = retryAfter(prev, cur, limit, elapsed, window)
+	}
+	return res
+}
+
+// retryAfter returns how long it takes for the estimate to drop below the limit.
+func retryAfter(prev, cur, limit int, elapsed, window time.Duration) time.Duration {
+	// If the current window alone is full, wait for it to end and let
+	// its weight decay enough in the next one.
+	if cur >= limit {
+		need := 1 - float64(limit-1)/float64(cur)
+		return window - elapsed + time.Duration(need*float64(window))
+	}
+	if prev == 0 {
+		return 0
+	}
+	// prev * (window-elapsed-wait)/window + cur <= limit-1
+	wait := float64(window-elapsed) - float64(limit-1-cur)/float64(prev)*float64(window)
+	return max(time.Duration(wait), time.Millisecond)
+}
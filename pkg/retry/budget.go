@@ -0,0 +1,58 @@
+package retry
+
+import "sync"
+
+// Budget limits the amount of retries a service may perform, so that a
+// failing dependency does not get hammered by every caller at once.
+//
+// It follows the gRPC retry throttling model: every retryable failure
+// removes one token, every success adds TokenRatio tokens, and retries are
+// only allowed while the bucket holds more than half of MaxTokens.
+// A nil *Budget allows every retry.
+type Budget struct {
+	mu         sync.Mutex
+	tokens     float64
+	maxTokens  float64
+	tokenRatio float64
+}
+
+// NewBudget creates a full budget.
+//   - maxTokens: bucket capacity, e.g. 10
+//   - tokenRatio: tokens refunded on each success, e.g. 0.1
+func NewBudget(maxTokens int, tokenRatio float64) *Budget {
+	return &Budget{
+		tokens:     float64(maxTokens),
+		maxTokens:  float64(maxTokens),
+		tokenRatio: tokenRatio,
+	}
+}
+
+// allow reports whether another retry may be attempted.
+func (b *Budget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens > b.maxTokens/2
+}
+
+// onSuccess refunds tokens after a successful attempt.
+func (b *Budget) onSuccess() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.maxTokens, b.tokens+b.tokenRatio)
+}
+
+// onFailure consumes a token after a retryable failure.
+func (b *Budget) onFailure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = max(0, b.tokens-1)
+}
@@ -0,0 +1,151 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"research-apm/pkg/errors"
+	"research-apm/pkg/tracer"
+)
+
+// Classifier decides whether an error returned by an attempt should be retried.
+type Classifier func(err error) bool
+
+// Policy describes how an operation is retried.
+// Zero values fall back to the defaults documented on each field.
+type Policy struct {
+	Name           string        // Span name prefix for each attempt (default "retry").
+	MaxAttempts    int           // Maximum number of attempts including the first one (default 3).
+	InitialBackoff time.Duration // Delay before the first retry (default 100ms).
+	MaxBackoff     time.Duration // Upper bound of a single delay (default 2s).
+	Multiplier     float64       // Backoff growth factor between attempts (default 2).
+	Jitter         float64       // Fraction (0..1) of the delay that is randomized (default 0.2).
+	MaxElapsed     time.Duration // Overall deadline for all attempts, 0 means only ctx deadline.
+	Classifier     Classifier    // Decides if an error is retryable (default errors.IsRetryable).
+	Budget         *Budget       // Optional shared retry budget to avoid retry storms.
+}
+
+// DefaultPolicy returns a policy with sane defaults for calls to a database or cache.
+func DefaultPolicy(name string) Policy {
+	return Policy{
+		Name:           name,
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		Classifier:     errors.IsRetryable,
+	}
+}
+
+// withDefaults fills zero fields of the policy.
+func (p Policy) withDefaults() Policy {
+	d := DefaultPolicy("retry")
+	if p.Name == "" {
+		p.Name = d.Name
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = d.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = d.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = d.MaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = d.Multiplier
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = d.Jitter
+	}
+	if p.Classifier == nil {
+		p.Classifier = d.Classifier
+	}
+	return p
+}
+
+// backoff returns the delay before the given retry (1-based).
+func (p Policy) backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	// Randomize part of the delay so that concurrent callers do not retry in lockstep.
+	delay -= delay * p.Jitter * rand.Float64()
+	return time.Duration(delay)
+}
+
+// Do runs fn until it succeeds, returns a non-retryable error,
+// the attempts are exhausted, the deadline passes or ctx is done.
+//
+// Each attempt is wrapped in its own APM span. When the operation gives up,
+// the error of the last attempt is returned unchanged so callers can still
+// classify it (e.g. errors.Wrap turns a Retryable into codes.Unavailable).
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) error {
+	policy = policy.withDefaults()
+
+	var deadline time.Time
+	if policy.MaxElapsed > 0 {
+		deadline = time.Now().Add(policy.MaxElapsed)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = runAttempt(ctx, policy.Name, attempt, fn)
+		if err == nil {
+			policy.Budget.onSuccess()
+			return nil
+		}
+		if !policy.Classifier(err) {
+			return err
+		}
+		policy.Budget.onFailure()
+		if attempt >= policy.MaxAttempts || !policy.Budget.allow() {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// DoValue is like Do but for operations that return a value.
+func DoValue[T any](ctx context.Context, policy Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := Do(ctx, policy, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+// runAttempt executes a single attempt inside an APM span.
+func runAttempt(ctx context.Context, name string, attempt int, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.StartSpan(ctx, fmt.Sprintf("%s.attempt", name))
+	defer span.End()
+	span.SetLabel("attempt", attempt)
+
+	err := fn(ctx)
+	if err != nil {
+		span.SetLabel("isRetryable", errors.IsRetryable(err))
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package retry_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"research-apm/pkg/errors"
+	"research-apm/pkg/retry"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fastPolicy keeps the tests quick by using tiny backoffs.
+func fastPolicy() retry.Policy {
+	p := retry.DefaultPolicy("test")
+	p.InitialBackoff = time.Millisecond
+	p.MaxBackoff = 2 * time.Millisecond
+	return p
+}
+
+// TestDoRetriesRetryableErrors verifies that retryable errors are retried until success.
+func TestDoRetriesRetryableErrors(t *testing.T) {
+	calls := 0
+	err := retry.Do(context.Background(), fastPolicy(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.NewRetryable(fmt.Errorf("temporary"))
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+// TestDoStopsOnPermanentError verifies that non-retryable errors are returned immediately.
+func TestDoStopsOnPermanentError(t *testing.T) {
+	calls := 0
+	want := fmt.Errorf("permanent")
+	err := retry.Do(context.Background(), fastPolicy(), func(ctx context.Context) error {
+		calls++
+		return want
+	})
+
+	assert.Equal(t, want, err)
+	assert.Equal(t, 1, calls)
+}
+
+// TestDoExhaustsAttempts verifies that the last error is returned unchanged
+// once MaxAttempts is reached.
+func TestDoExhaustsAttempts(t *testing.T) {
+	calls := 0
+	err := retry.Do(context.Background(), fastPolicy(), func(ctx context.Context) error {
+		calls++
+		return errors.NewRetryable(fmt.Errorf("attempt %d", calls))
+	})
+
+	assert.True(t, errors.IsRetryable(err))
+	assert.EqualError(t, err, "attempt 3")
+	assert.Equal(t, 3, calls)
+}
+
+// TestDoHonorsContext verifies that a cancelled context stops the retry loop.
+func TestDoHonorsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := fastPolicy()
+	p.InitialBackoff = time.Hour
+	p.MaxBackoff = time.Hour
+
+	calls := 0
+	go cancel()
+	err := retry.Do(ctx, p, func(ctx context.Context) error {
+		calls++
+		return errors.NewRetryable(fmt.Errorf("temporary"))
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+// TestDoBudget verifies that an exhausted budget prevents further retries.
+func TestDoBudget(t *testing.T) {
+	p := fastPolicy()
+	p.MaxAttempts = 10
+	p.Budget = retry.NewBudget(4, 0.1)
+
+	calls := 0
+	retry.Do(context.Background(), p, func(ctx context.Context) error {
+		calls++
+		return errors.NewRetryable(fmt.Errorf("temporary"))
+	})
+
+	// 4 tokens, retries stop once the bucket drops to half (2 tokens).
+	assert.Equal(t, 2, calls)
+}
+
+// TestDoValue verifies that the value of the successful attempt is returned.
+func TestDoValue(t *testing.T) {
+	calls := 0
+	v, err := retry.DoValue(context.Background(), fastPolicy(), func(ctx context.Context) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errors.NewRetryable(fmt.Errorf("temporary"))
+		}
+		return "ok", nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", v)
+}
@@ -0,0 +1,92 @@
+package tracer
+
+import (
+	"context"
+	"research-apm/pkg/errors"
+	"research-apm/pkg/errors/codes"
+)
+
+// CaptureLevel decides how client errors (AppError with a 4xx code) are
+// reported. Server errors are always captured.
+type CaptureLevel string
+
+const (
+	CaptureNone    CaptureLevel = "none"    // Only labels and transaction result, no error event (default).
+	CaptureWarning CaptureLevel = "warning" // Error event with level "warning".
+	CaptureAll     CaptureLevel = "error"   // Error event like a server error.
+)
+
+// clientErrorLevel is the capture level of client errors given to InitTracer.
+var clientErrorLevel = CaptureNone
+
+// errorReport describes how an error is reported to the backend.
+type errorReport struct {
+	failure bool   // Marks the outcome of the span or transaction as failure.
+	capture bool   // Sends an error event.
+	level   string // Level of the error event, empty for a regular error.
+	result  string // Code of an AppError.
+	labels  map[string]any
+}
+
+// newErrorReport classifies err. Errors that are not an AppError are
+// treated as server errors.
+func newErrorReport(err error) errorReport {
+	r := errorReport{
+		failure: true,
+		capture: true,
+		result:  string(codes.UnknownError),
+		labels:  map[string]any{"isRetryable": errors.IsRetryable(err)},
+	}
+	appErr, ok := err.(*errors.AppError)
+	if !ok {
+		return r
+	}
+	r.result = string(appErr.Code)
+	r.labels["error.code"] = string(appErr.Code)
+	if appErr.Code.HttpStatus() < 500 {
+		r.failure = false
+		switch clientErrorLevel {
+		case CaptureNone:
+			r.capture = false
+		case CaptureWarning:
+			r.level = string(CaptureWarning)
+		}
+	}
+	return r
+}
+
+// withTraceID adds the request trace id of ctx, if any, to the labels.
+func (r errorReport) withTraceID(ctx context.Context) errorReport {
+	if id := TraceIDFromContext(ctx); id != "" {
+		r.labels["traceId"] = id
+	}
+	return r
+}
+
+// traceIDKey is the context key of the request trace id.
+type traceIDKey struct{}
+
+// ContextWithTraceID returns a context carrying the request trace id
+// (X-Trace-ID), which is added as "traceId" label to reported errors.
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the request trace id of ctx, or "".
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// SetResult sets the result of the transaction in ctx from err:
+// the AppError code, or codes.Success when err is nil. The outcome is
+// failure only for 5xx codes, so client errors do not count towards
+// the error rate. The error code, isRetryable and traceId are added as labels.
+func SetResult(ctx context.Context, err error) {
+	if err == nil {
+		current.setResult(ctx, string(codes.Success), false, nil)
+		return
+	}
+	r := newErrorReport(err).withTraceID(ctx)
+	current.setResult(ctx, r.result, r.failure, r.labels)
+}
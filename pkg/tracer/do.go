@@ -0,0 +1,23 @@
+package tracer
+
+import "context"
+
+// Do runs fn inside a span named name. The outcome and labels of the
+// error returned by fn are set on the span; its error event is left to
+// the caller that handles the error, e.g. the HTTP response.
+func Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := StartSpan(ctx, name)
+	defer span.End()
+	err := fn(ctx)
+	span.SetOutcome(err)
+	return err
+}
+
+// DoValue is like Do but for operations that return a value.
+func DoValue[T any](ctx context.Context, name string, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, span := StartSpan(ctx, name)
+	defer span.End()
+	result, err := fn(ctx)
+	span.SetOutcome(err)
+	return result, err
+}
@@ -0,0 +1,307 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.elastic.co/apm/module/apmhttp/v2"
+	"go.elastic.co/apm/v2"
+	"go.elastic.co/apm/v2/transport"
+)
+
+// elasticBackend reports to Elastic APM.
+// A zero elasticBackend uses the default apm tracer.
+type elasticBackend struct {
+	tracer *apm.Tracer
+}
+
+// newElasticBackend builds an apm tracer from cfg and installs it as the
+// default tracer, so that contrib instrumentation (apmgin, apmgoredis, apmsql)
+// reports through it.
+func newElasticBackend(cfg Config) (*elasticBackend, error) {
+	serverURL, err := url.Parse(cfg.ServerUrl)
+	if err != nil {
+		return nil, fmt.Errorf("tracer: invalid server url %q", cfg.ServerUrl)
+	}
+	tr, err := transport.NewHTTPTransport(transport.HTTPTransportOptions{
+		ServerURLs:  []*url.URL{serverURL},
+		SecretToken: cfg.SecretToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tracer: create apm transport: %s", err.Error())
+	}
+	t, err := apm.NewTracerOptions(apm.TracerOptions{
+		ServiceName:        cfg.ServiceName,
+		ServiceVersion:     cfg.Version,
+		ServiceEnvironment: cfg.Env,
+		Transport:          tr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tracer: create apm tracer: %s", err.Error())
+	}
+	if cfg.IsUsingLogging {
+		t.SetLogger(stdoutLogger{})
+	}
+	apm.SetDefaultTracer(t)
+	return &elasticBackend{tracer: t}, nil
+}
+
+// apm returns the tracer of the backend, or the default apm tracer.
+func (b *elasticBackend) apm() *apm.Tracer {
+	if b.tracer == nil {
+		return apm.DefaultTracer()
+	}
+	return b.tracer
+}
+
+func (b *elasticBackend) name() Backend {
+	return BackendElastic
+}
+
+func (b *elasticBackend) startTransaction(ctx context.Context, name string, txType string) (context.Context, Span) {
+	tx := b.apm().StartTransaction(name, txType)
+	return apm.ContextWithTransaction(ctx, tx), &elasticTransaction{tracer: b.apm(), tx: tx}
+}
+
+func (b *elasticBackend) startSpan(ctx context.Context, name string, opts spanOptions) (context.Context, Span) {
+	// Without a transaction the span would be dropped, so start one that
+	// the span owns and ends together with itself.
+	var owned *apm.Transaction
+	if apm.TransactionFromContext(ctx) == nil {
+		owned = b.apm().StartTransaction(name, "custom")
+		ctx = apm.ContextWithTransaction(ctx, owned)
+	}
+	span, ctx := apm.StartSpan(ctx, name, opts.elasticType())
+	if opts.spanType == "db" {
+		span.Context.SetDatabase(apm.DatabaseSpanContext{
+			Instance:  opts.target,
+			Statement: opts.statement,
+			Type:      opts.system,
+		})
+	}
+	if opts.request != nil {
+		span.Context.SetHTTPRequest(opts.request)
+	}
+	if opts.spanType == "messaging" && opts.target != "" {
+		span.Context.SetMessage(apm.MessageSpanContext{QueueName: opts.target})
+	}
+	if opts.address != "" {
+		span.Context.SetDestinationAddress(opts.address, opts.port)
+	}
+	if opts.spanType != "" {
+		span.Context.SetServiceTarget(apm.ServiceTargetSpanContext{Type: opts.subtype, Name: opts.target})
+	}
+	return ctx, &elasticSpan{tracer: b.apm(), span: span, tx: owned}
+}
+
+func (b *elasticBackend) captureError(ctx context.Context, err error, r errorReport) {
+	e := newElasticError(b.apm(), err, r)
+	linkError(ctx, e)
+	e.Send()
+}
+
+func (b *elasticBackend) setResult(ctx context.Context, result string, failure bool, labels map[string]any) {
+	tx := apm.TransactionFromContext(ctx)
+	if tx == nil {
+		return
+	}
+	tx.Result = result
+	tx.Outcome = outcome(failure)
+	for k, v := range labels {
+		tx.Context.SetLabel(k, v)
+	}
+}
+
+func (b *elasticBackend) captureEvent(ctx context.Context, level string, message string, labels map[string]any) {
+	e := b.apm().NewErrorLog(apm.ErrorLogRecord{
+		Message:    message,
+		Level:      level,
+		LoggerName: "event",
+	})
+	for k, v := range labels {
+		e.Context.SetLabel(k, v)
+	}
+	linkError(ctx, e)
+	e.Send()
+}
+
+func (b *elasticBackend) inject(ctx context.Context, header http.Header) {
+	var tc apm.TraceContext
+	if span := apm.SpanFromContext(ctx); span != nil {
+		tc = span.TraceContext()
+	} else if tx := apm.TransactionFromContext(ctx); tx != nil {
+		tc = tx.TraceContext()
+	} else {
+		return
+	}
+	header.Set(apmhttp.W3CTraceparentHeader, apmhttp.FormatTraceparentHeader(tc))
+	if state := tc.State.String(); state != "" {
+		header.Set(apmhttp.TracestateHeader, state)
+	}
+}
+
+func (b *elasticBackend) flush(ctx context.Context) error {
+	b.apm().Flush(ctx.Done())
+	return ctx.Err()
+}
+
+func (b *elasticBackend) shutdown(ctx context.Context) error {
+	err := b.flush(ctx)
+	b.apm().Close()
+	return err
+}
+
+// stdoutLogger prints the apm agent logs to stdout.
+type stdoutLogger struct{}
+
+func (stdoutLogger) Debugf(format string, args ...any) {
+	fmt.Printf("[DEBUG] apm: "+format+"\n", args...)
+}
+
+func (stdoutLogger) Errorf(format string, args ...any) {
+	fmt.Printf("[ERROR] apm: "+format+"\n", args...)
+}
+
+func (stdoutLogger) Warningf(format string, args ...any) {
+	fmt.Printf("[WARN] apm: "+format+"\n", args...)
+}
+
+// elasticSpan adapts *apm.Span to Span.
+// tx is set when the span started its own transaction.
+type elasticSpan struct {
+	tracer *apm.Tracer
+	span   *apm.Span
+	tx     *apm.Transaction
+}
+
+func (s *elasticSpan) End() {
+	if s.tx == nil {
+		s.span.End()
+		return
+	}
+	// The span data is released on End, so read the outcome first.
+	s.tx.Outcome = s.span.Outcome
+	s.span.End()
+	s.tx.End()
+}
+
+func (s *elasticSpan) SetLabel(key string, value any) {
+	s.span.Context.SetLabel(key, value)
+}
+
+func (s *elasticSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	r := newErrorReport(err)
+	if r.capture {
+		e := newElasticError(s.tracer, err, r)
+		e.SetSpan(s.span)
+		e.Send()
+	}
+	s.setOutcome(r)
+}
+
+func (s *elasticSpan) SetOutcome(err error) {
+	if err == nil {
+		s.span.Outcome = outcome(false)
+		return
+	}
+	s.setOutcome(newErrorReport(err))
+}
+
+func (s *elasticSpan) setOutcome(r errorReport) {
+	for k, v := range r.labels {
+		s.span.Context.SetLabel(k, v)
+	}
+	s.span.Outcome = outcome(r.failure)
+}
+
+func (s *elasticSpan) SetStatusCode(code int) {
+	s.span.Context.SetHTTPStatusCode(code)
+	if code >= 400 {
+		s.span.Outcome = "failure"
+	}
+}
+
+// elasticTransaction adapts *apm.Transaction to Span.
+type elasticTransaction struct {
+	tracer *apm.Tracer
+	tx     *apm.Transaction
+}
+
+func (t *elasticTransaction) End() {
+	t.tx.End()
+}
+
+func (t *elasticTransaction) SetLabel(key string, value any) {
+	t.tx.Context.SetLabel(key, value)
+}
+
+func (t *elasticTransaction) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	r := newErrorReport(err)
+	if r.capture {
+		e := newElasticError(t.tracer, err, r)
+		e.SetTransaction(t.tx)
+		e.Send()
+	}
+	t.setOutcome(r)
+}
+
+func (t *elasticTransaction) SetOutcome(err error) {
+	if err == nil {
+		t.tx.Outcome = outcome(false)
+		return
+	}
+	t.setOutcome(newErrorReport(err))
+}
+
+func (t *elasticTransaction) setOutcome(r errorReport) {
+	for k, v := range r.labels {
+		t.tx.Context.SetLabel(k, v)
+	}
+	t.tx.Result = r.result
+	t.tx.Outcome = outcome(r.failure)
+}
+
+// newElasticError creates the error event of err as classified in r.
+// Errors with a level are sent as error logs so they can be told apart
+// from server errors.
+func newElasticError(t *apm.Tracer, err error, r errorReport) *apm.Error {
+	var e *apm.Error
+	if r.level != "" {
+		e = t.NewErrorLog(apm.ErrorLogRecord{
+			Message: err.Error(),
+			Level:   r.level,
+			Error:   err,
+		})
+	} else {
+		e = t.NewError(err)
+	}
+	for k, v := range r.labels {
+		e.Context.SetLabel(k, v)
+	}
+	return e
+}
+
+// linkError links e to the span or transaction in ctx, if any.
+func linkError(ctx context.Context, e *apm.Error) {
+	if span := apm.SpanFromContext(ctx); span != nil {
+		e.SetSpan(span)
+	} else if tx := apm.TransactionFromContext(ctx); tx != nil {
+		e.SetTransaction(tx)
+	}
+}
+
+// outcome returns the Elastic outcome value.
+func outcome(failure bool) string {
+	if failure {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,49 @@
+package tracer
+
+import (
+	"net/http"
+)
+
+// NewHTTPClient returns a copy of client whose requests are traced:
+// each request gets an exit span and carries the trace context
+// (traceparent) to the remote service. A nil client is treated as
+// an empty http.Client.
+func NewHTTPClient(client *http.Client) *http.Client {
+	c := &http.Client{}
+	if client != nil {
+		*c = *client
+	}
+	c.Transport = NewTransport(c.Transport)
+	return c
+}
+
+// NewTransport wraps next, or http.DefaultTransport when nil,
+// so that every request gets an exit span and carries the trace context.
+func NewTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &httpTransport{next: next}
+}
+
+// httpTransport is the http.RoundTripper returned by NewTransport.
+type httpTransport struct {
+	next http.RoundTripper
+}
+
+func (t *httpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := StartHTTPSpan(req.Context(), req)
+	defer span.End()
+
+	// A RoundTripper must not modify the caller's request.
+	req = req.Clone(ctx)
+	current.inject(ctx, req.Header)
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.SetOutcome(err)
+		return nil, err
+	}
+	span.SetStatusCode(resp.StatusCode)
+	return resp, nil
+}
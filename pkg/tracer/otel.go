@@ -0,0 +1,278 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the OpenTelemetry tracer used by this package.
+const instrumentationName = "research-apm/pkg/tracer"
+
+// otelBackend reports to an OTLP collector through the OpenTelemetry SDK.
+type otelBackend struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// newOtelBackend creates the OTLP exporter and registers the tracer provider
+// and W3C trace-context propagator globally, so that contrib instrumentation
+// (otelgin, gorm, redis) picks them up.
+func newOtelBackend(cfg Config) (*otelBackend, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.OtlpProtocol {
+	case "", "grpc":
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(cfg.OtlpEndpoint),
+			otlptracegrpc.WithHeaders(cfg.OtlpHeaders),
+		}
+		if cfg.OtlpInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(context.Background(), opts...)
+	case "http":
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(cfg.OtlpEndpoint),
+			otlptracehttp.WithHeaders(cfg.OtlpHeaders),
+		}
+		if cfg.OtlpInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracer: create otlp exporter: %s", err.Error())
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.Version),
+		semconv.DeploymentEnvironmentName(cfg.Env),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracer: create resource: %s", err.Error())
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return &otelBackend{provider: provider, tracer: provider.Tracer(instrumentationName)}, nil
+}
+
+func (b *otelBackend) name() Backend {
+	return BackendOtel
+}
+
+func (b *otelBackend) startTransaction(ctx context.Context, name string, txType string) (context.Context, Span) {
+	kind := trace.SpanKindInternal
+	switch txType {
+	case TransactionRequest:
+		kind = trace.SpanKindServer
+	case TransactionMessaging:
+		kind = trace.SpanKindConsumer
+	}
+	ctx, span := b.tracer.Start(ctx, name,
+		trace.WithNewRoot(),
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(attribute.String("transaction.type", txType)),
+	)
+	return ctx, &otelSpan{span: span}
+}
+
+func (b *otelBackend) startSpan(ctx context.Context, name string, opts spanOptions) (context.Context, Span) {
+	ctx, span := b.tracer.Start(ctx, name,
+		trace.WithSpanKind(otelKind(opts.kind)),
+		trace.WithAttributes(otelAttributes(opts)...),
+	)
+	return ctx, &otelSpan{span: span}
+}
+
+func (b *otelBackend) captureError(ctx context.Context, err error, r errorReport) {
+	recordOtelError(trace.SpanFromContext(ctx), err, r)
+}
+
+// setResult sets the result on the span in ctx. OpenTelemetry has no
+// transaction, the server span is ended by the HTTP instrumentation.
+func (b *otelBackend) setResult(ctx context.Context, result string, failure bool, labels map[string]any) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("transaction.result", result))
+	for k, v := range labels {
+		span.SetAttributes(toAttribute(k, v))
+	}
+	if failure {
+		span.SetStatus(otelcodes.Error, result)
+	}
+}
+
+func (b *otelBackend) captureEvent(ctx context.Context, level string, message string, labels map[string]any) {
+	attrs := make([]attribute.KeyValue, 0, len(labels)+1)
+	attrs = append(attrs, attribute.String("level", level))
+	for k, v := range labels {
+		attrs = append(attrs, toAttribute(k, v))
+	}
+	trace.SpanFromContext(ctx).AddEvent(message, trace.WithAttributes(attrs...))
+}
+
+func (b *otelBackend) inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+func (b *otelBackend) flush(ctx context.Context) error {
+	return b.provider.ForceFlush(ctx)
+}
+
+func (b *otelBackend) shutdown(ctx context.Context) error {
+	return b.provider.Shutdown(ctx)
+}
+
+// otelSpan adapts trace.Span to Span.
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
+
+func (s *otelSpan) SetLabel(key string, value any) {
+	s.span.SetAttributes(toAttribute(key, value))
+}
+
+func (s *otelSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	r := newErrorReport(err)
+	if r.capture {
+		recordOtelError(s.span, err, r)
+	}
+	s.setOutcome(err, r)
+}
+
+func (s *otelSpan) SetOutcome(err error) {
+	if err == nil {
+		return
+	}
+	s.setOutcome(err, newErrorReport(err))
+}
+
+func (s *otelSpan) setOutcome(err error, r errorReport) {
+	for k, v := range r.labels {
+		s.span.SetAttributes(toAttribute(k, v))
+	}
+	if r.failure {
+		s.span.SetStatus(otelcodes.Error, err.Error())
+	}
+}
+
+// recordOtelError adds the exception event of err as classified in r.
+func recordOtelError(span trace.Span, err error, r errorReport) {
+	attrs := make([]attribute.KeyValue, 0, len(r.labels)+1)
+	if r.level != "" {
+		attrs = append(attrs, attribute.String("level", r.level))
+	}
+	for k, v := range r.labels {
+		attrs = append(attrs, toAttribute(k, v))
+	}
+	span.RecordError(err, trace.WithAttributes(attrs...))
+}
+
+func (s *otelSpan) SetStatusCode(code int) {
+	s.span.SetAttributes(semconv.HTTPResponseStatusCode(code))
+	if code >= 400 {
+		s.span.SetStatus(otelcodes.Error, http.StatusText(code))
+	}
+}
+
+// otelKind maps a span kind onto its OpenTelemetry equivalent.
+func otelKind(kind spanKind) trace.SpanKind {
+	switch kind {
+	case kindClient:
+		return trace.SpanKindClient
+	case kindProducer:
+		return trace.SpanKindProducer
+	case kindConsumer:
+		return trace.SpanKindConsumer
+	}
+	return trace.SpanKindInternal
+}
+
+// otelAttributes maps the typed span fields onto semantic convention attributes.
+func otelAttributes(opts spanOptions) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	switch opts.spanType {
+	case "db":
+		attrs = append(attrs, semconv.DBSystemNameKey.String(opts.system))
+		if opts.target != "" {
+			attrs = append(attrs, semconv.DBNamespace(opts.target))
+		}
+		if opts.operation != "" {
+			attrs = append(attrs, semconv.DBOperationName(opts.operation))
+		}
+		if opts.statement != "" {
+			attrs = append(attrs, semconv.DBQueryText(opts.statement))
+		}
+	case "external":
+		if opts.request != nil {
+			attrs = append(attrs,
+				semconv.HTTPRequestMethodKey.String(opts.request.Method),
+				semconv.URLFull(opts.request.URL.String()),
+			)
+		}
+	case "messaging":
+		attrs = append(attrs,
+			semconv.MessagingSystemKey.String(opts.system),
+			semconv.MessagingOperationName(opts.operation),
+		)
+		if opts.target != "" {
+			attrs = append(attrs, semconv.MessagingDestinationName(opts.target))
+		}
+	}
+	if opts.address != "" {
+		attrs = append(attrs, semconv.ServerAddress(opts.address))
+	}
+	if opts.port != 0 {
+		attrs = append(attrs, semconv.ServerPort(opts.port))
+	}
+	return attrs
+}
+
+// toAttribute converts a label value into an OpenTelemetry attribute.
+func toAttribute(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
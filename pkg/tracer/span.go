@@ -0,0 +1,215 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Transaction types used with StartTransaction.
+const (
+	TransactionRequest   = "request"   // Incoming request handled by the service.
+	TransactionScheduled = "scheduled" // Cron or periodic background job.
+	TransactionMessaging = "messaging" // Message consumed from a queue.
+)
+
+// spanKind is the role of a span towards the remote side of the call.
+type spanKind int
+
+const (
+	kindInternal spanKind = iota
+	kindClient
+	kindProducer
+	kindConsumer
+)
+
+// spanOptions carries the typed fields of a span in a backend-neutral form.
+// Each backend maps them onto its own data model.
+type spanOptions struct {
+	spanType string // Elastic span type, e.g. "db", "external", "messaging".
+	subtype  string // Technology, e.g. "mongodb", "http", "redis".
+	action   string // Action, e.g. "query", "request", "send".
+	kind     spanKind
+
+	system    string // Remote system, e.g. "mongodb", "postgresql", "telegram".
+	target    string // Destination service name, e.g. database or queue name.
+	operation string // Operation name, e.g. "find", "GET", "send".
+	statement string // Database statement.
+	address   string // Destination host.
+	port      int    // Destination port.
+
+	request *http.Request // Outgoing HTTP request of an external span.
+}
+
+// elasticType returns the span type in the "type.subtype.action" form.
+func (o spanOptions) elasticType() string {
+	if o.spanType == "" {
+		return "custom"
+	}
+	parts := []string{o.spanType}
+	for _, p := range []string{o.subtype, o.action} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// HTTPSpan is a span around an outgoing HTTP request.
+type HTTPSpan interface {
+	Span
+	// SetStatusCode records the response status code. Status codes of 400
+	// and above mark the span as failed.
+	SetStatusCode(code int)
+}
+
+// DBSpan describes a call to a database.
+type DBSpan struct {
+	System    string // Database system, e.g. "mongodb", "postgresql", "mysql", "mssql".
+	Instance  string // Database name.
+	Operation string // Operation, e.g. "find", "SELECT".
+	Statement string // Statement without literal values, optional.
+	Address   string // Server host, optional.
+	Port      int    // Server port, optional.
+}
+
+// MessagingSpan describes sending or receiving a message.
+type MessagingSpan struct {
+	System      string // Messaging system, e.g. "kafka", "telegram".
+	Operation   string // "send" or "receive".
+	Destination string // Queue, topic or chat name.
+	Address     string // Broker host, optional.
+	Port        int    // Broker port, optional.
+}
+
+// CacheSpan describes a call to a cache.
+type CacheSpan struct {
+	System    string // Cache system, e.g. "redis".
+	Operation string // Command, e.g. "GET", "SET".
+	Key       string // Key accessed, optional.
+	Address   string // Server host, optional.
+	Port      int    // Server port, optional.
+}
+
+// StartTransaction starts a root transaction for work that is not part of
+// an incoming request, e.g. a cron job, and returns a context carrying it.
+// txType is one of the Transaction* constants.
+func StartTransaction(ctx context.Context, name string, txType string) (context.Context, Span) {
+	return current.startTransaction(ctx, name, txType)
+}
+
+// StartDBSpan starts a span around a database call.
+func StartDBSpan(ctx context.Context, db DBSpan) (context.Context, Span) {
+	name := db.Operation
+	if db.Instance != "" {
+		name = fmt.Sprintf("%s.%s", db.Instance, db.Operation)
+	}
+	return current.startSpan(ctx, name, spanOptions{
+		spanType:  "db",
+		subtype:   db.System,
+		action:    "query",
+		kind:      kindClient,
+		system:    db.System,
+		target:    db.Instance,
+		operation: db.Operation,
+		statement: db.Statement,
+		address:   db.Address,
+		port:      db.Port,
+	})
+}
+
+// StartHTTPSpan starts a span around an outgoing HTTP request.
+// Only the scheme and host of the URL are recorded.
+func StartHTTPSpan(ctx context.Context, req *http.Request) (context.Context, HTTPSpan) {
+	host, port := splitHostPort(req.URL.Host)
+	if port == 0 {
+		port = defaultPort(req.URL.Scheme)
+	}
+	ctx, span := current.startSpan(ctx, fmt.Sprintf("%s %s", req.Method, host), spanOptions{
+		spanType:  "external",
+		subtype:   "http",
+		action:    "request",
+		kind:      kindClient,
+		system:    "http",
+		target:    req.URL.Host,
+		operation: req.Method,
+		address:   host,
+		port:      port,
+		request:   redactRequest(req),
+	})
+	return ctx, span.(HTTPSpan)
+}
+
+// redactRequest returns a shallow copy of req whose URL keeps only the
+// scheme and host: the userinfo, path and query may carry credentials,
+// e.g. the Telegram bot token in "/bot<token>/sendMessage".
+func redactRequest(req *http.Request) *http.Request {
+	redacted := *req
+	redacted.URL = &url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host}
+	return &redacted
+}
+
+// StartMessagingSpan starts a span around sending or receiving a message.
+func StartMessagingSpan(ctx context.Context, msg MessagingSpan) (context.Context, Span) {
+	kind := kindProducer
+	if msg.Operation == "receive" {
+		kind = kindConsumer
+	}
+	return current.startSpan(ctx, fmt.Sprintf("%s %s", msg.System, msg.Operation), spanOptions{
+		spanType:  "messaging",
+		subtype:   msg.System,
+		action:    msg.Operation,
+		kind:      kind,
+		system:    msg.System,
+		target:    msg.Destination,
+		operation: msg.Operation,
+		address:   msg.Address,
+		port:      msg.Port,
+	})
+}
+
+// StartCacheSpan starts a span around a cache command.
+// Following the Elastic APM spec, cache calls are "db" spans whose subtype
+// is the cache system.
+func StartCacheSpan(ctx context.Context, cache CacheSpan) (context.Context, Span) {
+	statement := cache.Operation
+	if cache.Key != "" {
+		statement = fmt.Sprintf("%s %s", cache.Operation, cache.Key)
+	}
+	return current.startSpan(ctx, cache.Operation, spanOptions{
+		spanType:  "db",
+		subtype:   cache.System,
+		action:    "query",
+		kind:      kindClient,
+		system:    cache.System,
+		operation: cache.Operation,
+		statement: statement,
+		address:   cache.Address,
+		port:      cache.Port,
+	})
+}
+
+// splitHostPort splits "host:port", returning port 0 when there is none.
+func splitHostPort(hostport string) (string, int) {
+	host, p, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport, 0
+	}
+	port, _ := strconv.Atoi(p)
+	return host, port
+}
+
+// defaultPort returns the well-known port of an URL scheme.
+func defaultPort(scheme string) int {
+	switch scheme {
+	case "https":
+		return 443
+	case "http":
+		return 80
+	}
+	return 0
+}
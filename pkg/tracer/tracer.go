@@ -2,42 +2,176 @@ package tracer
 
 import (
 	"context"
-	"os"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Backend selects the tracing implementation.
+type Backend string
 
-	"go.elastic.co/apm/v2"
+const (
+	BackendElastic Backend = "elastic" // Elastic APM agent (go.elastic.co/apm/v2)
+	BackendOtel    Backend = "otel"    // OpenTelemetry SDK exporting OTLP
 )
 
+// Config holds the tracer configuration.
+// The Otlp* fields are only used by BackendOtel.
 type Config struct {
+	Backend        Backend // Tracing backend, defaults to BackendElastic.
 	Env            string
 	ServiceName    string
 	Version        string
 	ServerUrl      string
 	SecretToken    string
 	IsUsingLogging bool
+
+	ClientErrorLevel CaptureLevel // How 4xx AppErrors are captured, defaults to CaptureNone.
+
+	OtlpEndpoint string            // OTLP collector endpoint, e.g. "localhost:4317" (grpc) or "localhost:4318" (http).
+	OtlpProtocol string            // "grpc" (default) or "http".
+	OtlpInsecure bool              // Disable TLS towards the collector.
+	OtlpHeaders  map[string]string // Extra headers, e.g. Authorization.
+}
+
+// Span is a single timed operation, independent of the tracing backend.
+type Span interface {
+	// End finishes the span. It must be called exactly once.
+	End()
+	// SetLabel attaches a key/value pair to the span.
+	SetLabel(key string, value any)
+	// RecordError records err on the span and sends its error event.
+	// Call it once per error, where the error is handled: a request or
+	// job edge, or a call whose error is not returned.
+	RecordError(err error)
+	// SetOutcome records the outcome and labels of err, nil for success,
+	// without an error event. Use it on spans whose error is returned to
+	// a caller that records it.
+	SetOutcome(err error)
+}
+
+// backend is implemented by each tracing backend.
+type backend interface {
+	name() Backend
+	startTransaction(ctx context.Context, name string, txType string) (context.Context, Span)
+	startSpan(ctx context.Context, name string, opts spanOptions) (context.Context, Span)
+	captureError(ctx context.Context, err error, r errorReport)
+	setResult(ctx context.Context, result string, failure bool, labels map[string]any)
+	captureEvent(ctx context.Context, level string, message string, labels map[string]any)
+	inject(ctx context.Context, header http.Header)
+	flush(ctx context.Context) error
+	shutdown(ctx context.Context) error
 }
 
-func InitTracer(cfg Config) error {
-	os.Setenv("ELASTIC_APM_ENVIRONMENT", cfg.Env)
-	os.Setenv("ELASTIC_APM_SERVICE_VERSION", cfg.Version)
-	os.Setenv("ELASTIC_APM_SERVICE_NAME", cfg.ServiceName)
-	os.Setenv("ELASTIC_APM_SERVER_URL", cfg.ServerUrl)
-	os.Setenv("ELASTIC_APM_SECRET_TOKEN", cfg.SecretToken)
-	if cfg.IsUsingLogging {
-		os.Setenv("ELASTIC_APM_LOG_LEVEL", "debug")
-		os.Setenv("ELASTIC_APM_LOG_FILE", "stdout")
+// ShutdownFunc flushes buffered spans and releases the tracer.
+// It must be called once when the service stops.
+type ShutdownFunc func(ctx context.Context) error
+
+// current is the active backend. Until InitTracer is called it is
+// Elastic APM using the default apm tracer.
+var current backend = &elasticBackend{}
+
+// serviceName is the service name given to InitTracer.
+var serviceName string
+
+// InitTracer validates cfg and builds the tracing backend selected in it.
+// It must be called before any instrumented client is created,
+// since clients pick their instrumentation from the active backend.
+// The returned ShutdownFunc flushes and closes the tracer.
+func InitTracer(cfg Config) (ShutdownFunc, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	var b backend
+	var err error
+	switch cfg.Backend {
+	case "", BackendElastic:
+		b, err = newElasticBackend(cfg)
+	case BackendOtel:
+		b, err = newOtelBackend(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	current = b
+	serviceName = cfg.ServiceName
+	clientErrorLevel = cfg.ClientErrorLevel
+	if clientErrorLevel == "" {
+		clientErrorLevel = CaptureNone
 	}
-	return nil
+	return b.shutdown, nil
 }
 
-func StartSpan(ctx context.Context, name string) (context.Context, *apm.Span) {
-	tx := apm.TransactionFromContext(ctx)
-	if tx == nil {
-		tx = apm.DefaultTracer().StartTransaction(name, "custom")
+// validate reports every invalid field of the configuration at once.
+func (cfg Config) validate() error {
+	var errs []error
+	if cfg.ServiceName == "" {
+		errs = append(errs, fmt.Errorf("tracer: service name is required"))
 	}
-	span, ctx := apm.StartSpan(ctx, name, tx.Type)
-	return ctx, span
+	switch cfg.Backend {
+	case "", BackendElastic:
+		if cfg.ServerUrl == "" {
+			errs = append(errs, fmt.Errorf("tracer: server url is required for backend %q", BackendElastic))
+		} else if u, err := url.Parse(cfg.ServerUrl); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracer: invalid server url %q", cfg.ServerUrl))
+		}
+	case BackendOtel:
+		if cfg.OtlpEndpoint == "" {
+			errs = append(errs, fmt.Errorf("tracer: otlp endpoint is required for backend %q", BackendOtel))
+		}
+		if cfg.OtlpProtocol != "" && cfg.OtlpProtocol != "grpc" && cfg.OtlpProtocol != "http" {
+			errs = append(errs, fmt.Errorf("tracer: unknown otlp protocol %q", cfg.OtlpProtocol))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracer: unknown backend %q", cfg.Backend))
+	}
+	switch cfg.ClientErrorLevel {
+	case "", CaptureNone, CaptureWarning, CaptureAll:
+	default:
+		errs = append(errs, fmt.Errorf("tracer: unknown client error level %q", cfg.ClientErrorLevel))
+	}
+	return errors.Join(errs...)
+}
+
+// ServiceName returns the service name given to InitTracer.
+func ServiceName() string {
+	return serviceName
+}
+
+// CurrentBackend returns the active tracing backend.
+func CurrentBackend() Backend {
+	return current.name()
+}
+
+// StartSpan starts a span as a child of the span or transaction in ctx
+// and returns a context carrying the new span.
+// When ctx carries no transaction, the span starts its own transaction
+// and ends it together with the span.
+func StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return current.startSpan(ctx, name, spanOptions{})
 }
 
+// CaptureError reports err to the tracing backend, linked to the current span.
+// An AppError with a 4xx code is reported at the configured client error
+// level, other errors are always captured.
 func CaptureError(ctx context.Context, err error) {
-	apm.CaptureError(ctx, err).Send()
+	if err == nil {
+		return
+	}
+	r := newErrorReport(err).withTraceID(ctx)
+	if r.capture {
+		current.captureError(ctx, err, r)
+	}
+}
+
+// CaptureEvent reports a notable event (e.g. a circuit breaker state change)
+// to the tracing backend, linked to the current span or transaction if any.
+func CaptureEvent(ctx context.Context, level string, message string, labels map[string]any) {
+	current.captureEvent(ctx, level, message, labels)
+}
+
+// Flush sends all buffered spans to the backend, waiting until done or ctx ends.
+func Flush(ctx context.Context) error {
+	return current.flush(ctx)
 }
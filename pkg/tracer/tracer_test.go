@@ -0,0 +1,191 @@
+package tracer_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"research-apm/pkg/errors"
+	"research-apm/pkg/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"go.elastic.co/apm/v2"
+	"go.elastic.co/apm/v2/apmtest"
+	"go.elastic.co/apm/v2/model"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpReceiver is an in-process OTLP/HTTP trace receiver.
+type otlpReceiver struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var export collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	for _, rs := range export.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			r.spans = append(r.spans, ss.Spans...)
+		}
+	}
+	r.mu.Unlock()
+
+	resp, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(resp)
+}
+
+// TestElasticBackend verifies that a span started without a transaction is
+// reported inside exactly one transaction carrying the span outcome, and that
+// StartTransaction puts its transaction in the returned context.
+func TestElasticBackend(t *testing.T) {
+	recorder := apmtest.NewRecordingTracer()
+	defer recorder.Close()
+	previous := apm.DefaultTracer()
+	apm.SetDefaultTracer(recorder.Tracer)
+	defer apm.SetDefaultTracer(previous)
+	assert.Equal(t, tracer.BackendElastic, tracer.CurrentBackend())
+
+	_, span := tracer.StartSpan(context.Background(), "repository.GetUser")
+	span.RecordError(fmt.Errorf("dummy error get user"))
+	span.End()
+	recorder.Flush(nil)
+
+	payloads := recorder.Payloads()
+	if assert.Len(t, payloads.Transactions, 1) && assert.Len(t, payloads.Spans, 1) {
+		tx := payloads.Transactions[0]
+		assert.Equal(t, "repository.GetUser", tx.Name)
+		assert.Equal(t, "failure", tx.Outcome)
+		assert.Equal(t, "failure", payloads.Spans[0].Outcome)
+		assert.Equal(t, tx.ID, payloads.Spans[0].TransactionID)
+	}
+	assert.Len(t, payloads.Errors, 1)
+	recorder.ResetPayloads()
+
+	ctx, tx := tracer.StartTransaction(context.Background(), "job.Sync", "job")
+	current := apm.TransactionFromContext(ctx)
+	if assert.NotNil(t, current) {
+		id := model.SpanID(current.TraceContext().Span)
+		_, child := tracer.StartSpan(ctx, "repository.Sync")
+		child.End()
+		tx.End()
+		recorder.Flush(nil)
+
+		payloads = recorder.Payloads()
+		if assert.Len(t, payloads.Transactions, 1) && assert.Len(t, payloads.Spans, 1) {
+			assert.Equal(t, id, payloads.Transactions[0].ID)
+			assert.Equal(t, payloads.Transactions[0].ID, payloads.Spans[0].TransactionID)
+		}
+	}
+}
+
+// TestOtelBackend verifies that spans started through the tracer API are
+// exported over OTLP/HTTP with their labels, errors and parent relation.
+func TestOtelBackend(t *testing.T) {
+	receiver := &otlpReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	assert.Equal(t, tracer.BackendElastic, tracer.CurrentBackend())
+	shutdown, err := tracer.InitTracer(tracer.Config{
+		Backend:      tracer.BackendOtel,
+		ServiceName:  "tracer-test",
+		OtlpEndpoint: strings.TrimPrefix(server.URL, "http://"),
+		OtlpProtocol: "http",
+		OtlpInsecure: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, tracer.BackendOtel, tracer.CurrentBackend())
+
+	ctx, parent := tracer.StartSpan(context.Background(), "service.GetUser")
+	_, child := tracer.StartSpan(ctx, "repository.GetUser")
+	child.SetLabel("attempt", 2)
+	child.RecordError(fmt.Errorf("dummy error get user"))
+	child.End()
+	_, invalid := tracer.StartSpan(ctx, "service.CreateUser")
+	invalid.RecordError(errors.NewBadRequest("payload tidak valid", fmt.Errorf("name is required")))
+	invalid.End()
+	// Do leaves the error event to the caller that handles the error.
+	tracer.Do(ctx, "repository.CreateUser", func(ctx context.Context) error {
+		return fmt.Errorf("dummy error create user")
+	})
+
+	var traceparent string
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer remote.Close()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, remote.URL+"/bot123:SECRET/getMe?token=SECRET", nil)
+	resp, err := tracer.NewHTTPClient(nil).Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	_, db := tracer.StartDBSpan(ctx, tracer.DBSpan{System: "mongodb", Instance: "research", Operation: "find"})
+	db.End()
+	parent.End()
+
+	assert.NoError(t, shutdown(context.Background()))
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	byName := map[string]*tracepb.Span{}
+	for _, s := range receiver.spans {
+		byName[s.Name] = s
+	}
+	assert.Len(t, byName, 6)
+	assert.Equal(t, byName["service.GetUser"].SpanId, byName["repository.GetUser"].ParentSpanId)
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, byName["repository.GetUser"].Status.Code)
+	assert.Equal(t, "attempt", byName["repository.GetUser"].Attributes[0].Key)
+	assert.Len(t, byName["repository.GetUser"].Events, 1)
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, byName["repository.CreateUser"].Status.Code)
+	assert.Empty(t, byName["repository.CreateUser"].Events, "Do does not capture the error")
+	// The outbound request carries the trace context of its exit span.
+	exit := byName["GET 127.0.0.1"]
+	assert.Equal(t, tracepb.Span_SPAN_KIND_CLIENT, exit.Kind)
+	assert.Equal(t, fmt.Sprintf("00-%x-%x-01", exit.TraceId, exit.SpanId), traceparent)
+	// Only the scheme and host of the URL are recorded, never the credentials in the path.
+	for _, attr := range exit.Attributes {
+		assert.NotContains(t, attr.Value.GetStringValue(), "SECRET", attr.Key)
+		if attr.Key == "url.full" {
+			assert.Equal(t, remote.URL, attr.Value.GetStringValue())
+		}
+	}
+	// Client errors are labeled but neither fail the span nor add an exception event.
+	assert.NotEqual(t, tracepb.Status_STATUS_CODE_ERROR, byName["service.CreateUser"].GetStatus().GetCode())
+	assert.Empty(t, byName["service.CreateUser"].Events)
+	assert.Equal(t, tracepb.Span_SPAN_KIND_CLIENT, byName["research.find"].Kind)
+	assert.Equal(t, "db.system.name", byName["research.find"].Attributes[0].Key)
+	assert.Equal(t, "mongodb", byName["research.find"].Attributes[0].Value.GetStringValue())
+}
+
+// TestInitTracerValidation verifies that invalid configurations are rejected
+// with every problem reported at once.
+func TestInitTracerValidation(t *testing.T) {
+	_, err := tracer.InitTracer(tracer.Config{Backend: "zipkin"})
+	assert.ErrorContains(t, err, "service name is required")
+	assert.ErrorContains(t, err, "unknown backend")
+
+	_, err = tracer.InitTracer(tracer.Config{ServiceName: "tracer-test", ServerUrl: "localhost"})
+	assert.ErrorContains(t, err, "invalid server url")
+
+	_, err = tracer.InitTracer(tracer.Config{Backend: tracer.BackendOtel, ServiceName: "tracer-test", OtlpProtocol: "udp"})
+	assert.ErrorContains(t, err, "otlp endpoint is required")
+	assert.ErrorContains(t, err, "unknown otlp protocol")
+}
@@ -3,54 +3,238 @@ package config
 import (
 	"context"
 	"fmt"
-	"os"
+	"net/http"
+	"research-apm/pkg/app"
+	"research-apm/pkg/configx"
+	"research-apm/pkg/database/elasticx"
+	"research-apm/pkg/database/redisx"
+	"research-apm/pkg/ginx"
+	"research-apm/pkg/health"
+	"research-apm/pkg/tracer"
 	"research-apm/services/alert/internal/repository"
 	"research-apm/services/alert/internal/service"
-	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v9"
 	"github.com/go-co-op/gocron/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/go-telegram/bot"
 )
 
-type App struct {
-	schduler gocron.Scheduler
-}
+// NewApp registers the components of the Alert Worker in dependency order:
+// config, tracer, Elasticsearch and Telegram clients, Redis and leader
+// election, the scheduler and the health server.
+// They are started by app.Run and stopped in reverse order.
+func NewApp() *app.App {
+	a := app.New(app.Config{Name: "Alert Worker"})
 
-func (a *App) Shutdown() {
-	if err := a.schduler.Shutdown(); err != nil {
-		fmt.Println("[ERROR] shutdown schduler", err.Error())
-	}
-}
+	var (
+		env      Env
+		secrets  *configx.Resolver
+		esClient *elasticsearch.Client
+		b        *bot.Bot
+	)
 
-func NewApp(ctx context.Context) (*App, error) {
-	esClient, err := elasticsearch.NewClient(elasticsearch.Config{
-		Addresses: strings.Split(os.Getenv("ELASTIC_HOST"), ","),
-		Username:  os.Getenv("ELASTIC_USER"),
-		Password:  os.Getenv("ELASTIC_PASS"),
-	})
-	if err != nil {
-		return nil, err
-	}
-	b, err := bot.New(os.Getenv("TELEGRAM_BOT_TOKEN"))
-	if err != nil {
-		return nil, err
-	}
-	scheduler, err := gocron.NewScheduler()
-	repo := repository.NewRepository(
-		esClient,
-		b,
-		os.Getenv("TELEGRAM_CHAT_ID"),
+	var (
+		watchCtx    context.Context
+		cancelWatch context.CancelFunc
 	)
-	scheduler.NewJob(
-		gocron.CronJob("0 * * * * *", true),
-		gocron.NewTask(service.SendAlert, ctx, repo),
-		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	a.Add(app.Component{
+		Name: "config",
+		Start: func(ctx context.Context) (err error) {
+			env, secrets, err = LoadEnv(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Print("[INFO] config\n", configx.String(env))
+			watchCtx, cancelWatch = context.WithCancel(ctx)
+			return nil
+		},
+		// The Elasticsearch password and the Telegram token are read per
+		// request. The other clients keep the credentials they were created
+		// with, so their rotation shuts the worker down gracefully and it
+		// restarts with the new value.
+		Run: func(ctx context.Context) error {
+			return secrets.Watch(watchCtx, env.SecretRefreshInterval, "ELASTIC_PASS", "TELEGRAM_BOT_TOKEN")
+		},
+		Stop: func(ctx context.Context) error {
+			cancelWatch()
+			return nil
+		},
+	})
+
+	var shutdownTracer tracer.ShutdownFunc
+	a.Add(app.Component{
+		Name: "tracer",
+		Start: func(ctx context.Context) (err error) {
+			shutdownTracer, err = tracer.InitTracer(tracer.Config{
+				Backend:        tracer.Backend(env.Tracer.Backend),
+				Env:            env.Env,
+				ServiceName:    env.ServiceName,
+				Version:        env.ServiceVersion,
+				ServerUrl:      env.Tracer.ServerUrl,
+				SecretToken:    env.Tracer.SecretToken,
+				IsUsingLogging: env.Tracer.IsUsingLogging,
+				OtlpEndpoint:   env.Tracer.OtlpEndpoint,
+				OtlpProtocol:   env.Tracer.OtlpProtocol,
+				OtlpInsecure:   env.Tracer.OtlpInsecure,
+			})
+			return err
+		},
+		Stop: func(ctx context.Context) error {
+			return shutdownTracer(ctx)
+		},
+	})
+
+	a.Add(app.Component{
+		Name: "elasticsearch",
+		Start: func(ctx context.Context) (err error) {
+			esClient, err = elasticsearch.NewClient(elasticsearch.Config{
+				Addresses: env.ElasticHosts,
+				Transport: newBasicAuth(env.ElasticUser, secretValue(secrets, "ELASTIC_PASS", env.ElasticPass), tracer.NewTransport(nil)),
+			})
+			if err != nil {
+				return err
+			}
+			health.Default.Register("elasticsearch", elasticx.HealthCheck(esClient))
+			return nil
+		},
+	})
+
+	a.Add(app.Component{
+		Name: "telegram",
+		Start: func(ctx context.Context) (err error) {
+			b, err = bot.New(
+				env.TelegramBotToken,
+				bot.WithHTTPClient(time.Minute, tracer.NewHTTPClient(&http.Client{
+					Timeout:   time.Minute,
+					Transport: newBotToken(env.TelegramBotToken, secretValue(secrets, "TELEGRAM_BOT_TOKEN", env.TelegramBotToken), nil),
+				})),
+			)
+			return err
+		},
+	})
+
+	// With Redis, replicas elect a leader and only the leader runs the
+	// alert job, so alerts are not sent twice.
+	var (
+		dbRedis redis.UniversalClient
+		elector *redisx.Elector
 	)
-	if err != nil {
-		return nil, err
-	}
-	scheduler.Start()
-	return &App{schduler: scheduler}, nil
+	a.Add(app.Component{
+		Name: "redis",
+		Start: func(ctx context.Context) (err error) {
+			if env.Redis.URL == "" && len(env.Redis.Addrs) == 0 {
+				fmt.Println("[WARN] REDIS_URL is not set, leader election disabled")
+				return nil
+			}
+			dbRedis, err = redisx.NewClient(ctx, redisx.Config{
+				Url:              env.Redis.URL,
+				UseApm:           true,
+				Mode:             env.Redis.Mode,
+				Addrs:            env.Redis.Addrs,
+				MasterName:       env.Redis.MasterName,
+				Password:         env.Redis.Password,
+				SentinelPassword: env.Redis.SentinelPassword,
+			})
+			if err != nil {
+				return err
+			}
+			health.Default.Register("redis", redisx.HealthCheck(dbRedis))
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return redisx.Disconnect(dbRedis)
+		},
+	})
+	a.Add(app.Component{
+		Name: "leader election",
+		Start: func(ctx context.Context) error {
+			if dbRedis == nil {
+				return nil
+			}
+			elector = redisx.NewElector(redisx.NewLocker(dbRedis, "research_apm"), redisx.ElectorConfig{
+				Key: env.ServiceName,
+				TTL: env.Redis.LeaderTTL,
+			})
+			elector.Start(ctx)
+			return nil
+		},
+		// Resigns so another replica takes over right away.
+		Stop: func(ctx context.Context) error {
+			if elector == nil {
+				return nil
+			}
+			return elector.Stop(ctx)
+		},
+	})
+
+	var scheduler gocron.Scheduler
+	a.Add(app.Component{
+		Name: "scheduler",
+		Start: func(ctx context.Context) (err error) {
+			var options []gocron.SchedulerOption
+			if elector != nil {
+				options = append(options, gocron.WithDistributedElector(elector))
+			}
+			scheduler, err = gocron.NewScheduler(options...)
+			if err != nil {
+				return err
+			}
+			repo := repository.NewRepository(
+				esClient,
+				b,
+				env.TelegramChatID,
+			)
+			_, err = scheduler.NewJob(
+				gocron.CronJob(env.AlertCron, true),
+				gocron.NewTask(service.SendAlert, ctx, repo),
+				gocron.WithSingletonMode(gocron.LimitModeReschedule),
+			)
+			if err != nil {
+				scheduler.Shutdown()
+				return err
+			}
+			scheduler.Start()
+			return nil
+		},
+		// Shutdown waits for a running alert, so it is bounded by ctx.
+		Stop: func(ctx context.Context) error {
+			done := make(chan error, 1)
+			go func() {
+				done <- scheduler.Shutdown()
+			}()
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				return fmt.Errorf("scheduler: %s", ctx.Err().Error())
+			}
+		},
+	})
 
+	// Registered last so readiness fails before the scheduler stops.
+	// The worker takes no traffic, so there is nothing to wait for.
+	var server *ginx.Server
+	a.Add(app.Component{
+		Name: "health server",
+		Start: func(ctx context.Context) error {
+			server = ginx.NewServer(ginx.NewEngine(ginx.WithHealth()), ginx.ServerConfig{
+				Addr:         env.HealthAddr,
+				PreStopDelay: -1,
+			})
+			if err := server.Listen(); err != nil {
+				return err
+			}
+			fmt.Println("[INFO] Run health server On", server.Addr())
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			return server.Serve()
+		},
+		Stop: func(ctx context.Context) error {
+			return server.Shutdown(ctx)
+		},
+	})
+	return a
 }
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"research-apm/pkg/configx"
+	"time"
+)
+
+// Env is the configuration of the alert service, loaded by LoadEnv.
+type Env struct {
+	Env            string `env:"ENV" default:"development"`
+	ServiceName    string `env:"SERVICE_NAME"` // See elasticAPMDefaults.
+	ServiceVersion string `env:"SERVICE_VERSION"`
+	AlertCron      string `env:"ALERT_CRON" default:"0 * * * * *"`
+	HealthAddr     string `env:"HEALTH_ADDR" default:":8080"` // Serves /healthz and /readyz.
+
+	Tracer TracerEnv
+
+	VaultAddr             string        `env:"VAULT_ADDR"`
+	VaultToken            string        `env:"VAULT_TOKEN" secret:"true"`
+	SecretRefreshInterval time.Duration `env:"SECRET_REFRESH_INTERVAL" default:"5m"`
+
+	ElasticHosts []string `env:"ELASTIC_HOST" required:"true"`
+	ElasticUser  string   `env:"ELASTIC_USER"`
+	ElasticPass  string   `env:"ELASTIC_PASS" secret:"true"`
+
+	TelegramBotToken string `env:"TELEGRAM_BOT_TOKEN" required:"true" secret:"true"`
+	TelegramChatID   string `env:"TELEGRAM_CHAT_ID" required:"true"`
+
+	// Redis elects the replica running the alert job. Without it every
+	// replica runs the job, so run a single replica.
+	Redis RedisEnv
+}
+
+// RedisEnv is the configuration of redisx: REDIS_URL for a standalone
+// server, or REDIS_MODE=sentinel|cluster with REDIS_ADDRS.
+type RedisEnv struct {
+	URL              string        `env:"REDIS_URL" secret:"true"`
+	Mode             string        `env:"REDIS_MODE" default:"standalone"`
+	Addrs            []string      `env:"REDIS_ADDRS"`
+	MasterName       string        `env:"REDIS_MASTER_NAME"`
+	Password         string        `env:"REDIS_PASSWORD" secret:"true"`
+	SentinelPassword string        `env:"REDIS_SENTINEL_PASSWORD" secret:"true"`
+	LeaderTTL        time.Duration `env:"LEADER_TTL" default:"15s"`
+}
+
+// TracerEnv is the configuration of pkg/tracer.
+type TracerEnv struct {
+	Backend        string `env:"TRACER_BACKEND" default:"elastic"`
+	ServerUrl      string `env:"APM_SERVER_URL"`
+	SecretToken    string `env:"APM_SECRET_TOKEN" secret:"true"`
+	IsUsingLogging bool   `env:"APM_LOGGING" default:"false"`
+	OtlpEndpoint   string `env:"OTLP_ENDPOINT"`
+	OtlpProtocol   string `env:"OTLP_PROTOCOL" default:"grpc"`
+	OtlpInsecure   bool   `env:"OTLP_INSECURE" default:"false"`
+}
+
+// LoadEnv loads Env from the environment, then ./.env, then the YAML file
+// in CONFIG_FILE (default ./config.yaml). Missing files are skipped.
+// Values that are secret references (file:///run/secrets/x, or
+// vault://path#key when VAULT_ADDR is set) are resolved; the returned
+// resolver keeps them for rotation.
+func LoadEnv(ctx context.Context) (Env, *configx.Resolver, error) {
+	var env Env
+	dotenv, err := configx.DotEnv(".env")
+	if err != nil {
+		return env, nil, err
+	}
+	path, ok := os.LookupEnv("CONFIG_FILE")
+	if !ok {
+		path = "config.yaml"
+	}
+	file, err := configx.YAML(path)
+	if err != nil {
+		return env, nil, err
+	}
+	if err := configx.Load(&env, configx.Env(), dotenv, file); err != nil {
+		return env, nil, err
+	}
+	elasticAPMDefaults(&env)
+
+	resolver, err := configx.NewSecretResolver(ctx, env.VaultAddr, env.VaultToken)
+	if err != nil {
+		return env, nil, err
+	}
+	if err := configx.ResolveSecrets(ctx, &env, resolver); err != nil {
+		return env, nil, err
+	}
+	return env, resolver, nil
+}
+
+// elasticAPMDefaults fills the service name and Elastic APM settings left
+// empty from the ELASTIC_APM_* variables, then from the defaults of the
+// APM agent, as the worker did before its tracer was configured explicitly.
+func elasticAPMDefaults(env *Env) {
+	fallback := func(value *string, key, def string) {
+		if *value != "" {
+			return
+		}
+		if v := os.Getenv(key); v != "" {
+			*value = v
+			return
+		}
+		*value = def
+	}
+	fallback(&env.ServiceName, "ELASTIC_APM_SERVICE_NAME", filepath.Base(os.Args[0]))
+	if env.Tracer.Backend == "elastic" {
+		fallback(&env.Tracer.ServerUrl, "ELASTIC_APM_SERVER_URL", "http://localhost:8200")
+		fallback(&env.Tracer.SecretToken, "ELASTIC_APM_SECRET_TOKEN", "")
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"net/http"
+	"research-apm/pkg/configx"
+	"strings"
+)
+
+// basicAuth sets the Elasticsearch credentials on every request. The
+// password is read per request so a rotated secret applies without a restart.
+type basicAuth struct {
+	user     string
+	password func() string
+	next     http.RoundTripper
+}
+
+func newBasicAuth(user string, password func() string, next http.RoundTripper) http.RoundTripper {
+	if user == "" {
+		return next
+	}
+	return &basicAuth{user: user, password: password, next: next}
+}
+
+func (t *basicAuth) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.user, t.password())
+	return t.next.RoundTrip(req)
+}
+
+// secretValue returns a getter of the current value of key, following
+// rotations when the value came from a secret reference.
+func secretValue(secrets *configx.Resolver, key string, value string) func() string {
+	if secret := secrets.Secret(key); secret != nil {
+		return secret.Value
+	}
+	return func() string { return value }
+}
+
+// botToken puts the current Telegram bot token in the request path. The bot
+// client builds its URLs from the token it was created with, so a rotated
+// token applies without a restart.
+type botToken struct {
+	created string
+	token   func() string
+	next    http.RoundTripper
+}
+
+func newBotToken(created string, token func() string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &botToken{created: created, token: token, next: next}
+}
+
+func (t *botToken) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := t.token()
+	if token == t.created {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Path = strings.Replace(req.URL.Path, "/bot"+t.created+"/", "/bot"+token+"/", 1)
+	req.URL.RawPath = ""
+	return t.next.RoundTrip(req)
+}
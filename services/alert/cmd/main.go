@@ -4,27 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/signal"
 	"research-apm/services/alert/cmd/config"
-	"syscall"
 )
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	app, err := config.NewApp(ctx)
-	if err != nil {
+	// Starts the components (tracer, clients, scheduler), waits for
+	// a termination signal (Ctrl+C / Docker stop / etc.) and stops them
+	// in reverse order.
+	if err := config.NewApp().Run(context.Background()); err != nil {
 		fmt.Println("[ERROR]", err.Error())
-		return
+		os.Exit(1)
 	}
-	defer app.Shutdown()
-	fmt.Println("[INFO] Run Alert Worker")
-
-	// Wait for termination signal (Ctrl+C / Docker stop / etc.)
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
-
-	fmt.Println("[INFO] Shutdown Alert Worker")
 }
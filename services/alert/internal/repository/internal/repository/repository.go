@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"research-apm/pkg/tracer"
 	"research-apm/services/alert/internal/entity"
 	"research-apm/services/alert/internal/repository/internal/model"
 	"strings"
@@ -106,11 +107,19 @@ func (repo *Repository) SendTelegram(ctx context.Context, data entity.Alert) err
 		return nil
 	}
 
-	if _, err := repo.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+	sendCtx, span := tracer.StartMessagingSpan(ctx, tracer.MessagingSpan{
+		System:      "telegram",
+		Operation:   "send",
+		Destination: repo.chatID,
+	})
+	_, err = repo.telegramBot.SendMessage(sendCtx, &bot.SendMessageParams{
 		ChatID:    repo.chatID,
 		Text:      model.NewMessage(data),
 		ParseMode: models.ParseModeHTML,
-	}); err != nil {
+	})
+	span.SetOutcome(err)
+	span.End()
+	if err != nil {
 		return err
 	}
 
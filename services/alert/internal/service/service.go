@@ -3,14 +3,19 @@ package service
 import (
 	"context"
 	"fmt"
+	"research-apm/pkg/tracer"
 	"research-apm/services/alert/internal/repository"
 
 	"golang.org/x/sync/errgroup"
 )
 
 func SendAlert(ctx context.Context, repo repository.Repository) error {
+	ctx, tx := tracer.StartTransaction(ctx, "SendAlert", tracer.TransactionScheduled)
+	defer tx.End()
+
 	items, err := repo.GetAlert(ctx)
 	if err != nil {
+		tx.RecordError(err)
 		return err
 	}
 
@@ -21,6 +26,7 @@ func SendAlert(ctx context.Context, repo repository.Repository) error {
 		g.Go(func() error {
 			if err := repo.SendTelegram(ctx, it); err != nil {
 				fmt.Println(err.Error())
+				tracer.CaptureError(ctx, err)
 			}
 			return nil
 		})
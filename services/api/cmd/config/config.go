@@ -2,9 +2,11 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
+	"research-apm/pkg/app"
+	"research-apm/pkg/configx"
 	"research-apm/pkg/database/gormx"
 	"research-apm/pkg/database/gormx/dialector/apm/mysql"
 	"research-apm/pkg/database/gormx/dialector/apm/pgsql"
@@ -12,112 +14,325 @@ import (
 	"research-apm/pkg/database/mongox"
 	"research-apm/pkg/database/redisx"
 	"research-apm/pkg/ginx"
+	"research-apm/pkg/health"
 	"research-apm/pkg/tracer"
 	"research-apm/services/api/internal/delivery"
+	"research-apm/services/api/internal/entity"
 	"research-apm/services/api/internal/repository"
 	"research-apm/services/api/internal/service"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"gorm.io/gorm"
 )
 
-type App struct {
-	Server      *http.Server
-	MongoClient *mongo.Client
-	DBMessage   *gorm.DB
-	DBClientDO  *gorm.DB
-	DBProfil    *gorm.DB
-}
+// NewApp registers the components of the User API in dependency order:
+// config, tracer, databases, caches, log file and HTTP server. They are
+// started by app.Run and stopped in reverse order.
+func NewApp() *app.App {
+	// The stop timeout covers the pre-stop delay and the drain timeout
+	// of the HTTP server.
+	a := app.New(app.Config{Name: "User API", StopTimeout: 30 * time.Second})
 
-func (a *App) Shutdown(ctx context.Context) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-	if err := a.Server.Shutdown(ctx); err != nil {
-		fmt.Println(err.Error())
-	}
-	mongox.Disconnect(a.MongoClient)
-	gormx.Disconnect(a.DBMessage)
-	gormx.Disconnect(a.DBClientDO)
-	gormx.Disconnect(a.DBProfil)
-}
+	var (
+		env        Env
+		dbClient   *mongo.Client
+		dbMessage  *gorm.DB
+		dbClientDo *gorm.DB
+		dbProfil   *gorm.DB
+		dbRedis    redis.UniversalClient
+		logFile    *os.File
+	)
 
-func NewApp(ctx context.Context) (*App, error) {
-	if err := tracer.InitTracer(tracer.Config{
-		Env:            os.Getenv("ENV"),
-		ServiceName:    os.Getenv("SERVICE_NAME"),
-		Version:        os.Getenv("SERVICE_VERSION"),
-		ServerUrl:      os.Getenv("APM_SERVER_URL"),
-		SecretToken:    os.Getenv("APM_SECRET_TOKEN"),
-		IsUsingLogging: true,
-	}); err != nil {
-		return nil, err
-	}
-	dbClient, err := mongox.NewClient(mongox.Config{
-		Uri:             os.Getenv("MONGO_DB_URL"),
-		MinPoolSize:     2,
-		MaxPoolSize:     5,
-		MaxConnIdleTime: 10 * time.Minute,
+	var (
+		secrets     *configx.Resolver
+		watchCtx    context.Context
+		cancelWatch context.CancelFunc
+	)
+	a.Add(app.Component{
+		Name: "config",
+		Start: func(ctx context.Context) (err error) {
+			env, secrets, err = LoadEnv(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Print("[INFO] config\n", configx.String(env))
+			watchCtx, cancelWatch = context.WithCancel(ctx)
+			return nil
+		},
+		// Database pools, Redis and the tracer keep the credentials they were
+		// created with, so a rotated secret shuts the service down gracefully
+		// and it restarts with the new value.
+		Run: func(ctx context.Context) error {
+			return secrets.Watch(watchCtx, env.SecretRefreshInterval)
+		},
+		Stop: func(ctx context.Context) error {
+			cancelWatch()
+			return nil
+		},
 	})
-	if err != nil {
-		return nil, err
-	}
-	dbMessage, err := gormx.NewClient(gormx.Config{
-		Dialector:  pgsql.NewDialector(os.Getenv("MESSAGE_DB_URL")),
-		PoolConfig: nil,
-		GormConfig: nil,
+
+	var shutdownTracer tracer.ShutdownFunc
+	a.Add(app.Component{
+		Name: "tracer",
+		Start: func(ctx context.Context) (err error) {
+			shutdownTracer, err = tracer.InitTracer(tracer.Config{
+				Backend:        tracer.Backend(env.Tracer.Backend),
+				Env:            env.Env,
+				ServiceName:    env.ServiceName,
+				Version:        env.ServiceVersion,
+				ServerUrl:      env.Tracer.ServerUrl,
+				SecretToken:    env.Tracer.SecretToken,
+				IsUsingLogging: env.Tracer.IsUsingLogging,
+				OtlpEndpoint:   env.Tracer.OtlpEndpoint,
+				OtlpProtocol:   env.Tracer.OtlpProtocol,
+				OtlpInsecure:   env.Tracer.OtlpInsecure,
+
+				ClientErrorLevel: tracer.CaptureLevel(env.Tracer.ClientErrorLevel),
+			})
+			return err
+		},
+		Stop: func(ctx context.Context) error {
+			return shutdownTracer(ctx)
+		},
 	})
-	if err != nil {
-		return nil, err
-	}
-	dbClientDo, err := gormx.NewClient(gormx.Config{
-		Dialector:  sqlserver.NewDialector(os.Getenv("CLIENT_DO_DB_URL")),
-		PoolConfig: nil,
-		GormConfig: nil,
+
+	a.Add(app.Component{
+		Name: "mongodb",
+		Start: func(ctx context.Context) (err error) {
+			dbClient, err = mongox.NewClient(mongox.Config{
+				Uri:             env.MongoURL,
+				MinPoolSize:     env.MongoMinPoolSize,
+				MaxPoolSize:     env.MongoMaxPoolSize,
+				MaxConnIdleTime: env.MongoMaxConnIdleTime,
+				UseApm:          true,
+			})
+			if err != nil {
+				return err
+			}
+			mongox.RegisterMetrics("mongodb", dbClient)
+			health.Default.Register("mongodb", mongox.HealthCheck(dbClient))
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			mongox.Disconnect(dbClient)
+			return nil
+		},
 	})
-	if err != nil {
-		return nil, err
-	}
-	dbProfil, err := gormx.NewClient(gormx.Config{
-		Dialector:  mysql.NewDialector(os.Getenv("PROFIL_DB_URL")),
-		PoolConfig: nil,
-		GormConfig: nil,
+
+	a.Add(
+		sqlComponent("postgres", "message", &dbMessage, &env, pgsql.NewDialector, func() (string, []string) {
+			return env.MessageDBURL, env.MessageDBReplicaURLs
+		}),
+		sqlComponent("sqlserver", "client_do", &dbClientDo, &env, sqlserver.NewDialector, func() (string, []string) {
+			return env.ClientDoDBURL, nil
+		}),
+		sqlComponent("mysql", "profil", &dbProfil, &env, mysql.NewDialector, func() (string, []string) {
+			return env.ProfilDBURL, nil
+		}),
+	)
+
+	a.Add(app.Component{
+		Name: "redis",
+		Start: func(ctx context.Context) (err error) {
+			dbRedis, err = redisx.NewClient(ctx, redisConfig(env.Redis))
+			if err != nil {
+				return err
+			}
+			redisx.RegisterMetrics("redis", dbRedis)
+			health.Default.Register("redis", redisx.HealthCheck(dbRedis))
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return redisx.Disconnect(dbRedis)
+		},
 	})
-	if err != nil {
-		return nil, err
-	}
-	dbRedis, err := redisx.NewClient(ctx, redisx.Config{
-		Url:    os.Getenv("REDIS_URL"),
-		UseApm: true,
+
+	// The client_do cache listens for invalidations from the other replicas
+	// until it is closed.
+	var clientDOCache *redisx.Tiered[[]entity.ClientDo]
+	a.Add(app.Component{
+		Name: "client_do cache",
+		Start: func(ctx context.Context) error {
+			clientDOCache = redisx.NewTiered(dbRedis, redisx.NewCache(dbRedis, redisx.CacheConfig[[]entity.ClientDo]{
+				Name:   "client_do",
+				Prefix: "research_apm",
+			}), redisx.TieredConfig{Name: "client_do", L1Size: 1})
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return clientDOCache.Close()
+		},
 	})
-	if err != nil {
-		return nil, err
+
+	a.Add(app.Component{
+		Name: "log file",
+		Start: func(ctx context.Context) (err error) {
+			logFile, err = os.OpenFile(env.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			return err
+		},
+		Stop: func(ctx context.Context) error {
+			return errors.Join(ginx.FlushLogs(ctx), logFile.Close())
+		},
+	})
+
+	// Registered last so it stops first: readiness fails, requests drain,
+	// then the logs and transactions are flushed before the clients close.
+	var server *ginx.Server
+	a.Add(app.Component{
+		Name: "http server",
+		Start: func(ctx context.Context) error {
+			rateLimit := ginx.RateLimitConfig{
+				Limit:  env.RateLimit,
+				Window: env.RateLimitWindow,
+				Store:  redisx.NewRateLimitStore(dbRedis, "research_apm.ratelimit"),
+			}
+			if err := rateLimit.Validate(); err != nil {
+				return err
+			}
+			tls, err := tlsConfig(env.HTTP)
+			if err != nil {
+				return err
+			}
+			engine := ginx.NewEngine(
+				ginx.WithMetrics(),
+				ginx.WithHealth(),
+				ginx.WithTraceID(),
+				ginx.WithClientCert(),
+				ginx.WithRateLimit(rateLimit),
+				ginx.WithLogFile(ctx, logFile, ginx.LogConfig{
+					AppName:      env.ServiceName,
+					AppSite:      "",
+					AppEnv:       env.Env,
+					AppVersion:   env.ServiceVersion,
+					AppDBVersion: "",
+				}),
+				ginx.WithTracing(),
+			)
+			server = delivery.NewDelivery(
+				engine,
+				service.NewService(repository.NewRepository(dbClient, dbMessage, dbClientDo, dbProfil, dbRedis, clientDOCache)),
+				ginx.ServerConfig{
+					Addr:         env.HTTP.Addr,
+					ReadTimeout:  env.HTTP.ReadTimeout,
+					WriteTimeout: env.HTTP.WriteTimeout,
+					IdleTimeout:  env.HTTP.IdleTimeout,
+					MaxBodyBytes: env.HTTP.MaxBodyBytes,
+					PreStopDelay: env.HTTP.PreStopDelay,
+					DrainTimeout: env.HTTP.DrainTimeout,
+					TLS:          tls,
+				},
+				env.HTTP.ClientDOSubjects,
+			)
+			// Listen here so a busy port fails the start instead of the run.
+			if err := server.Listen(); err != nil {
+				return err
+			}
+			fmt.Println("[INFO] Run User API On", server.Addr())
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			return server.Serve()
+		},
+		Stop: func(ctx context.Context) error {
+			return server.Shutdown(ctx)
+		},
+	})
+	return a
+}
+
+// redisConfig returns the redisx configuration of the topology in env.
+func redisConfig(env RedisEnv) redisx.Config {
+	cfg := redisx.Config{
+		Url:              env.URL,
+		UseApm:           true,
+		Mode:             env.Mode,
+		Addrs:            env.Addrs,
+		MasterName:       env.MasterName,
+		Username:         env.Username,
+		Password:         env.Password,
+		SentinelPassword: env.SentinelPassword,
+		ReadFromReplicas: env.ReadFromReplicas,
+		PoolSize:         env.PoolSize,
+		MinIdleConns:     env.MinIdleConns,
+		PoolTimeout:      env.PoolTimeout,
+		DialTimeout:      env.DialTimeout,
+		ReadTimeout:      env.ReadTimeout,
+		WriteTimeout:     env.WriteTimeout,
 	}
-	logFile, err := os.OpenFile("./log.jsonl", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, err
+	if env.TLSEnabled {
+		cfg.TLS = &redisx.TLSConfig{
+			CAFile:   env.TLSCAFile,
+			CertFile: env.TLSCertFile,
+			KeyFile:  env.TLSKeyFile,
+		}
 	}
-	engine := ginx.NewEngine(
-		ginx.WithTraceID(),
-		ginx.WithLogFile(ctx, logFile, ginx.LogConfig{
-			AppName:      os.Getenv("SERVICE_NAME"),
-			AppSite:      "",
-			AppEnv:       os.Getenv("ENV"),
-			AppVersion:   os.Getenv("SERVICE_VERSION"),
-			AppDBVersion: "",
-		}),
-		ginx.WithElasticAPM(),
-	)
-	server := delivery.NewDelivery(
-		engine,
-		service.NewService(repository.NewRepository(dbClient, dbMessage, dbClientDo, dbProfil, dbRedis)),
-	)
-	return &App{
-		Server:      server,
-		MongoClient: dbClient,
-		DBMessage:   dbMessage,
-		DBClientDO:  dbClientDo,
-		DBProfil:    dbProfil,
+	return cfg
+}
+
+// tlsConfig returns the HTTPS settings, or nil to serve plain HTTP when no
+// TLS file is set. A partial configuration is an error rather than a silent
+// fallback to HTTP.
+func tlsConfig(env HTTPEnv) (*ginx.TLSConfig, error) {
+	if env.TLSCertFile == "" && env.TLSKeyFile == "" {
+		if env.TLSClientCAFile != "" || env.TLSRequireClientCert {
+			return nil, errors.New("HTTP_TLS_CLIENT_CA_FILE and HTTP_TLS_REQUIRE_CLIENT_CERT require HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if env.TLSCertFile == "" || env.TLSKeyFile == "" {
+		return nil, errors.New("HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together")
+	}
+	if env.TLSRequireClientCert && env.TLSClientCAFile == "" {
+		return nil, errors.New("HTTP_TLS_REQUIRE_CLIENT_CERT requires HTTP_TLS_CLIENT_CA_FILE")
+	}
+	return &ginx.TLSConfig{
+		CertFile:          env.TLSCertFile,
+		KeyFile:           env.TLSKeyFile,
+		ClientCAFile:      env.TLSClientCAFile,
+		RequireClientCert: env.TLSRequireClientCert,
+		ReloadInterval:    env.TLSReloadInterval,
 	}, nil
+}
 
+// sqlComponent creates a gorm client, with the read replicas returned by
+// urls, with the shared pool configuration and registers its metrics under
+// name and its health check under system.
+func sqlComponent(system, name string, db **gorm.DB, env *Env, dialector func(string) gorm.Dialector, urls func() (string, []string)) app.Component {
+	return app.Component{
+		Name: system,
+		Start: func(ctx context.Context) (err error) {
+			primary, replicaURLs := urls()
+			replicas := make([]gormx.Replica, len(replicaURLs))
+			for i, url := range replicaURLs {
+				replicas[i] = gormx.Replica{Name: fmt.Sprintf("%s-replica-%d", name, i+1), Dialector: dialector(url)}
+			}
+			*db, err = gormx.NewClient(gormx.Config{
+				Dialector: dialector(primary),
+				PoolConfig: &gormx.PoolConfig{
+					MaxOpenCon:     env.SQLPool.MaxOpenCon,
+					MaxIdleCon:     env.SQLPool.MaxIdleCon,
+					MaxLifetimeCon: env.SQLPool.MaxLifetimeCon,
+					MaxIdleTimeCon: env.SQLPool.MaxIdleTimeCon,
+				},
+				GormConfig:           nil,
+				Replicas:             replicas,
+				ReplicaPolicy:        env.SQLReplicaPolicy,
+				ReplicaCheckInterval: env.SQLReplicaCheck,
+			})
+			if err != nil {
+				return err
+			}
+			if err := gormx.RegisterMetrics(name, *db); err != nil {
+				// Stop is only called for started components.
+				return errors.Join(err, gormx.Disconnect(*db))
+			}
+			health.Default.Register(system, gormx.HealthCheck(*db))
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return gormx.Disconnect(*db)
+		},
+	}
 }
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"os"
+	"research-apm/pkg/configx"
+	"time"
+)
+
+// Env is the configuration of the api service, loaded by LoadEnv.
+type Env struct {
+	Env            string `env:"ENV" default:"development"`
+	ServiceName    string `env:"SERVICE_NAME" required:"true"`
+	ServiceVersion string `env:"SERVICE_VERSION"`
+	LogFile        string `env:"LOG_FILE" default:"./log.jsonl"`
+
+	HTTP HTTPEnv
+
+	Tracer TracerEnv
+
+	VaultAddr             string        `env:"VAULT_ADDR"`
+	VaultToken            string        `env:"VAULT_TOKEN" secret:"true"`
+	SecretRefreshInterval time.Duration `env:"SECRET_REFRESH_INTERVAL" default:"5m"`
+
+	MongoURL             string        `env:"MONGO_DB_URL" required:"true" secret:"true"`
+	MongoMinPoolSize     uint64        `env:"MONGO_MIN_POOL_SIZE" default:"2"`
+	MongoMaxPoolSize     uint64        `env:"MONGO_MAX_POOL_SIZE" default:"5"`
+	MongoMaxConnIdleTime time.Duration `env:"MONGO_MAX_CONN_IDLE_TIME" default:"10m"`
+
+	MessageDBURL  string `env:"MESSAGE_DB_URL" required:"true" secret:"true"`
+	ClientDoDBURL string `env:"CLIENT_DO_DB_URL" required:"true" secret:"true"`
+	ProfilDBURL   string `env:"PROFIL_DB_URL" required:"true" secret:"true"`
+	SQLPool       SQLPoolEnv
+
+	// Read replicas of the message database, comma separated.
+	MessageDBReplicaURLs []string      `env:"MESSAGE_DB_REPLICA_URLS" secret:"true"`
+	SQLReplicaPolicy     string        `env:"SQL_REPLICA_POLICY" default:"round_robin"`
+	SQLReplicaCheck      time.Duration `env:"SQL_REPLICA_CHECK_INTERVAL" default:"10s"`
+
+	Redis RedisEnv
+
+	RateLimit       int           `env:"RATE_LIMIT" default:"600"`
+	RateLimitWindow time.Duration `env:"RATE_LIMIT_WINDOW" default:"1m"`
+}
+
+// HTTPEnv is the configuration of the HTTP server.
+type HTTPEnv struct {
+	Addr         string        `env:"HTTP_ADDR" default:":8080"`
+	ReadTimeout  time.Duration `env:"HTTP_READ_TIMEOUT" default:"30s"`
+	WriteTimeout time.Duration `env:"HTTP_WRITE_TIMEOUT" default:"30s"`
+	IdleTimeout  time.Duration `env:"HTTP_IDLE_TIMEOUT" default:"2m"`
+	MaxBodyBytes int64         `env:"HTTP_MAX_BODY_BYTES" default:"10485760"`
+	PreStopDelay time.Duration `env:"HTTP_PRE_STOP_DELAY" default:"5s"`
+	DrainTimeout time.Duration `env:"HTTP_DRAIN_TIMEOUT" default:"20s"`
+
+	// HTTPS is served when both files are set.
+	TLSCertFile          string        `env:"HTTP_TLS_CERT_FILE"`
+	TLSKeyFile           string        `env:"HTTP_TLS_KEY_FILE"`
+	TLSClientCAFile      string        `env:"HTTP_TLS_CLIENT_CA_FILE"`
+	TLSRequireClientCert bool          `env:"HTTP_TLS_REQUIRE_CLIENT_CERT" default:"false"`
+	TLSReloadInterval    time.Duration `env:"HTTP_TLS_RELOAD_INTERVAL" default:"30s"`
+
+	// Certificate common names or subjects allowed on /client-do.
+	ClientDOSubjects []string `env:"CLIENT_DO_ALLOWED_SUBJECTS" sep:";"`
+}
+
+// TracerEnv is the configuration of pkg/tracer.
+type TracerEnv struct {
+	Backend          string `env:"TRACER_BACKEND" default:"elastic"`
+	ServerUrl        string `env:"APM_SERVER_URL"`
+	SecretToken      string `env:"APM_SECRET_TOKEN" secret:"true"`
+	IsUsingLogging   bool   `env:"APM_LOGGING" default:"true"`
+	OtlpEndpoint     string `env:"OTLP_ENDPOINT"`
+	OtlpProtocol     string `env:"OTLP_PROTOCOL" default:"grpc"`
+	OtlpInsecure     bool   `env:"OTLP_INSECURE" default:"false"`
+	ClientErrorLevel string `env:"TRACER_CLIENT_ERROR_LEVEL" default:"none"`
+}
+
+// RedisEnv is the configuration of redisx: REDIS_URL for a standalone
+// server, or REDIS_MODE=sentinel|cluster with REDIS_ADDRS.
+type RedisEnv struct {
+	URL              string        `env:"REDIS_URL" secret:"true"`
+	Mode             string        `env:"REDIS_MODE" default:"standalone"`
+	Addrs            []string      `env:"REDIS_ADDRS"`
+	MasterName       string        `env:"REDIS_MASTER_NAME"`
+	Username         string        `env:"REDIS_USERNAME"`
+	Password         string        `env:"REDIS_PASSWORD" secret:"true"`
+	SentinelPassword string        `env:"REDIS_SENTINEL_PASSWORD" secret:"true"`
+	ReadFromReplicas bool          `env:"REDIS_READ_FROM_REPLICAS" default:"false"`
+	TLSEnabled       bool          `env:"REDIS_TLS" default:"false"`
+	TLSCAFile        string        `env:"REDIS_TLS_CA_FILE"`
+	TLSCertFile      string        `env:"REDIS_TLS_CERT_FILE"`
+	TLSKeyFile       string        `env:"REDIS_TLS_KEY_FILE"`
+	PoolSize         int           `env:"REDIS_POOL_SIZE" default:"20"`
+	MinIdleConns     int           `env:"REDIS_MIN_IDLE_CONNS" default:"2"`
+	PoolTimeout      time.Duration `env:"REDIS_POOL_TIMEOUT" default:"4s"`
+	DialTimeout      time.Duration `env:"REDIS_DIAL_TIMEOUT" default:"5s"`
+	ReadTimeout      time.Duration `env:"REDIS_READ_TIMEOUT" default:"3s"`
+	WriteTimeout     time.Duration `env:"REDIS_WRITE_TIMEOUT" default:"3s"`
+}
+
+// SQLPoolEnv is the connection pool of the sql databases.
+type SQLPoolEnv struct {
+	MaxOpenCon     int           `env:"SQL_MAX_OPEN_CONNS" default:"10"`
+	MaxIdleCon     int           `env:"SQL_MAX_IDLE_CONNS" default:"5"`
+	MaxLifetimeCon time.Duration `env:"SQL_CONN_MAX_LIFETIME" default:"30m"`
+	MaxIdleTimeCon time.Duration `env:"SQL_CONN_MAX_IDLE_TIME" default:"5m"`
+}
+
+// LoadEnv loads Env from the environment, then ./.env, then the YAML file
+// in CONFIG_FILE (default ./config.yaml). Missing files are skipped.
+// Values that are secret references (file:///run/secrets/x, or
+// vault://path#key when VAULT_ADDR is set) are resolved; the returned
+// resolver keeps them for rotation.
+func LoadEnv(ctx context.Context) (Env, *configx.Resolver, error) {
+	var env Env
+	dotenv, err := configx.DotEnv(".env")
+	if err != nil {
+		return env, nil, err
+	}
+	path, ok := os.LookupEnv("CONFIG_FILE")
+	if !ok {
+		path = "config.yaml"
+	}
+	file, err := configx.YAML(path)
+	if err != nil {
+		return env, nil, err
+	}
+	if err := configx.Load(&env, configx.Env(), dotenv, file); err != nil {
+		return env, nil, err
+	}
+
+	resolver, err := configx.NewSecretResolver(ctx, env.VaultAddr, env.VaultToken)
+	if err != nil {
+		return env, nil, err
+	}
+	if err := configx.ResolveSecrets(ctx, &env, resolver); err != nil {
+		return env, nil, err
+	}
+	return env, resolver, nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"research-apm/pkg/database/gormx"
+	"research-apm/pkg/database/gormx/dialector/mysql"
+	"research-apm/pkg/database/gormx/dialector/pgsql"
+	"research-apm/pkg/database/gormx/dialector/sqlserver"
+	"research-apm/pkg/database/gormx/migrate"
+	"research-apm/services/api/internal/migrations"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const migrateUsage = `usage: api migrate [-dry-run] [-db message|client_do|profil] up | down [steps] | status`
+
+// Migrate runs the migrate subcommand on the sql databases, all of them
+// unless -db is set:
+//
+//	api migrate up            apply the pending migrations
+//	api migrate down [steps]  revert the last steps migrations (default 1)
+//	api migrate status        list the migrations and whether they are applied
+func Migrate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Println(migrateUsage) }
+	dryRun := flags.Bool("dry-run", false, "print the migrations without running them")
+	only := flags.String("db", "", "migrate only this database")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	command, steps := flags.Arg(0), 1
+	switch {
+	case command == "down" && flags.NArg() == 2:
+		n, err := strconv.Atoi(flags.Arg(1))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("migrate: steps must be a positive number, got %q", flags.Arg(1))
+		}
+		steps = n
+	case (command == "up" || command == "status" || command == "down") && flags.NArg() == 1:
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	env, _, err := LoadEnv(ctx)
+	if err != nil {
+		return err
+	}
+	targets := []struct {
+		name      string
+		dialector gorm.Dialector
+		fsys      fs.FS
+	}{
+		{"message", pgsql.NewDialector(env.MessageDBURL), migrations.Message},
+		{"client_do", sqlserver.NewDialector(env.ClientDoDBURL), migrations.ClientDO},
+		{"profil", mysql.NewDialector(env.ProfilDBURL), migrations.Profil},
+	}
+	found := false
+	for _, target := range targets {
+		if *only != "" && *only != target.name {
+			continue
+		}
+		found = true
+		if err := migrateDB(ctx, target.name, target.dialector, target.fsys, command, steps, *dryRun); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("migrate: unknown database %q", *only)
+	}
+	return nil
+}
+
+func migrateDB(ctx context.Context, name string, dialector gorm.Dialector, fsys fs.FS, command string, steps int, dryRun bool) (err error) {
+	db, err := gormx.NewClient(gormx.Config{Dialector: dialector})
+	if err != nil {
+		return fmt.Errorf("migrate %s: %s", name, err.Error())
+	}
+	defer func() {
+		err = errors.Join(err, gormx.Disconnect(db))
+	}()
+	// Tables of the other services may live in the same databases, so the
+	// version table is named after the service.
+	m, err := migrate.New(db, fsys, migrate.Config{Table: "api_schema_migrations", DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+
+	would := ""
+	if dryRun {
+		would = "would be "
+	}
+	switch command {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println("[INFO]", name+":", len(applied), "migration(s)", would+"applied")
+	case "down":
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Println("[INFO]", name+":", len(reverted), "migration(s)", would+"reverted")
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\n", name, s.Migration, state)
+		}
+	}
+	return nil
+}
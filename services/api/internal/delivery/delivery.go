@@ -1,42 +1,60 @@
 package delivery
 
 import (
-	"net/http"
+	"research-apm/pkg/bulkhead"
 	"research-apm/pkg/errors"
 	"research-apm/pkg/errors/codes"
+	"research-apm/pkg/ginx"
 	"research-apm/pkg/ginx/response"
-	"research-apm/pkg/tracer"
 	"research-apm/services/api/internal/entity"
 	"research-apm/services/api/internal/service"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func NewDelivery(engine *gin.Engine, service service.Service) *http.Server {
+// NewDelivery registers the routes on engine and returns the server.
+// When clientDOSubjects is not empty, /client-do (bank-to-bank client data)
+// only accepts mTLS clients with one of these certificate subjects.
+func NewDelivery(engine *gin.Engine, service service.Service, cfg ginx.ServerConfig, clientDOSubjects []string) *ginx.Server {
 	route := engine.Group("api/v1")
-	route.GET("/user", GetUser(service))
-	route.POST("/user", Create(service))
-	route.GET("/message", GetMessage(service))
-	route.GET("/client-do", GetClientDO(service))
-	route.GET("/profil", GetProfil(service))
-	return &http.Server{
-		Handler: engine,
-		Addr:    ":8080",
+
+	// Each route group gets its own bulkhead so a slow dependency
+	// (e.g. sqlserver behind /client-do) cannot starve the other routes.
+	user := route.Group("/user", groupLimit("user"))
+	user.GET("", GetUser(service))
+	user.POST("", Create(service))
+	route.GET("/message", groupLimit("message"), GetMessage(service))
+	// Clients are authenticated before they take a bulkhead slot.
+	var clientDOChain []gin.HandlerFunc
+	if len(clientDOSubjects) > 0 {
+		clientDOChain = append(clientDOChain, ginx.RequireClientCert(clientDOSubjects...))
 	}
+	clientDO := route.Group("/client-do", append(clientDOChain, groupLimit("client-do"))...)
+	clientDO.GET("", GetClientDO(service))
+	route.GET("/profil", groupLimit("profil"), GetProfil(service))
+	return ginx.NewServer(engine, cfg)
+}
+
+// groupLimit creates the bulkhead middleware of a route group.
+func groupLimit(name string) gin.HandlerFunc {
+	return ginx.Bulkhead(bulkhead.New(bulkhead.Config{
+		Name:          name,
+		MaxConcurrent: 50,
+		MaxQueue:      100,
+		QueueTimeout:  2 * time.Second,
+	}))
 }
+
 func GetUser(service service.Service) gin.HandlerFunc {
 	return func(ginCtx *gin.Context) {
-		ctx, span := tracer.StartSpan(ginCtx.Request.Context(), "delivery.GetUser")
-		defer span.End()
-		result, err := service.GetUser(ctx)
+		result, err := service.GetUser(ginCtx.Request.Context())
 		response.New(ginCtx, result, err)
 
 	}
 }
 func Create(service service.Service) gin.HandlerFunc {
 	return func(ginCtx *gin.Context) {
-		ctx, span := tracer.StartSpan(ginCtx.Request.Context(), "delivery.Create")
-		defer span.End()
 		type Body struct {
 			Name    string `json:"name" binding:"required"`
 			Address string `json:"address" binding:"required"`
@@ -46,7 +64,7 @@ func Create(service service.Service) gin.HandlerFunc {
 			response.New(ginCtx, nil, errors.New(codes.BadRequest, "payload tidak valid", err))
 			return
 		}
-		result, err := service.CreateUser(ctx, entity.User{
+		result, err := service.CreateUser(ginCtx.Request.Context(), entity.User{
 			Name:    body.Name,
 			Address: body.Address,
 		})
@@ -56,9 +74,7 @@ func Create(service service.Service) gin.HandlerFunc {
 
 func GetMessage(service service.Service) gin.HandlerFunc {
 	return func(ginCtx *gin.Context) {
-		ctx, span := tracer.StartSpan(ginCtx.Request.Context(), "delivery.GetMessage")
-		defer span.End()
-		result, err := service.GetMessage(ctx)
+		result, err := service.GetMessage(ginCtx.Request.Context())
 		response.New(ginCtx, result, err)
 
 	}
@@ -66,18 +82,14 @@ func GetMessage(service service.Service) gin.HandlerFunc {
 
 func GetClientDO(service service.Service) gin.HandlerFunc {
 	return func(ginCtx *gin.Context) {
-		ctx, span := tracer.StartSpan(ginCtx.Request.Context(), "delivery.GetClientDO")
-		defer span.End()
-		result, err := service.GetClientDO(ctx)
+		result, err := service.GetClientDO(ginCtx.Request.Context())
 		response.New(ginCtx, result, err)
 
 	}
 }
 func GetProfil(service service.Service) gin.HandlerFunc {
 	return func(ginCtx *gin.Context) {
-		ctx, span := tracer.StartSpan(ginCtx.Request.Context(), "delivery.GetProfil")
-		defer span.End()
-		result, err := service.GetProfil(ctx)
+		result, err := service.GetProfil(ginCtx.Request.Context())
 		response.New(ginCtx, result, err)
 
 	}
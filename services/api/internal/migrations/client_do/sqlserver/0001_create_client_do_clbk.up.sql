@@ -0,0 +1,12 @@
+-- Baseline: the table may already exist in databases created before
+-- the migrations.
+IF OBJECT_ID(N'REFERALNASABAH.dbo.CLIENT_DO_CLBK', N'U') IS NULL
+CREATE TABLE REFERALNASABAH.dbo.CLIENT_DO_CLBK (
+    id             INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    nik            VARCHAR(50)       NOT NULL,
+    status_nasabah VARCHAR(5)        NOT NULL,
+    status_send    TINYINT           NOT NULL DEFAULT 0,
+    send_at        DATETIME2         NULL,
+    created_at     DATETIME2         NULL,
+    updated_at     DATETIME2         NULL
+);
@@ -0,0 +1,24 @@
+-- Baseline: the table may already exist in databases created before
+-- the migrations.
+CREATE TABLE IF NOT EXISTS public.messages (
+    id               SERIAL       PRIMARY KEY,
+    event_id         VARCHAR(40)  NOT NULL,
+    batch_id         VARCHAR(40)  NOT NULL,
+    project_id       INTEGER      NOT NULL,
+    project_name     VARCHAR(50)  NOT NULL,
+    channel_id       INTEGER      NOT NULL,
+    channel_name     VARCHAR(50)  NOT NULL,
+    channel_platform SMALLINT     NOT NULL,
+    template_id      INTEGER      NOT NULL,
+    template_name    VARCHAR(50)  NOT NULL,
+    message          TEXT         NOT NULL,
+    destination      TEXT         NOT NULL,
+    creator_id       INTEGER      NOT NULL,
+    creator_name     VARCHAR(50)  NOT NULL,
+    status           SMALLINT     NOT NULL,
+    attempt          INTEGER      NOT NULL,
+    result           TEXT         NOT NULL,
+    send_at          TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ
+);
@@ -0,0 +1,30 @@
+// Package migrations embeds the schema migrations of the sql databases
+// of the api service, one directory per database and dialect, for
+// gormx/migrate.
+//
+// The 0001 migrations adopt tables that already hold production data, so
+// they have no down file and "api migrate down" refuses to drop them.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed message profil client_do
+var files embed.FS
+
+// Migrations of each database.
+var (
+	Message  = sub("message")
+	Profil   = sub("profil")
+	ClientDO = sub("client_do")
+)
+
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}
@@ -0,0 +1,11 @@
+-- Baseline: the table may already exist in databases created before
+-- the migrations.
+CREATE TABLE IF NOT EXISTS apm_research.profil (
+    id           INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    nama         VARCHAR(100) NOT NULL,
+    email        VARCHAR(100) NOT NULL,
+    phone_number VARCHAR(30)  NOT NULL,
+    alamat       VARCHAR(200) NOT NULL,
+    created_at   DATETIME(3)  NULL,
+    updated_at   DATETIME(3)  NULL
+);
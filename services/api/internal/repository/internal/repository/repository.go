@@ -2,11 +2,11 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"math/rand"
+	"research-apm/pkg/cache"
+	"research-apm/pkg/database/gormx"
 	"research-apm/pkg/database/mongox"
-	"research-apm/pkg/tracer"
 	"research-apm/services/api/internal/entity"
 	"research-apm/services/api/internal/repository/internal/model"
 	"time"
@@ -23,16 +23,20 @@ type Repository struct {
 	dbMessage  *gorm.DB
 	dbClientDO *gorm.DB
 	dbProfil   *gorm.DB
-	dbRedis    *redis.Client
+	dbRedis    redis.UniversalClient
+
+	clientDOCache cache.Cache[[]entity.ClientDo]
 }
 
-func NewRepository(mongoClient *mongo.Client, dbMessage *gorm.DB, dbClientDO *gorm.DB, dbProfil *gorm.DB, dbRedis *redis.Client) *Repository {
+func NewRepository(mongoClient *mongo.Client, dbMessage *gorm.DB, dbClientDO *gorm.DB, dbProfil *gorm.DB, dbRedis redis.UniversalClient, clientDOCache cache.Cache[[]entity.ClientDo]) *Repository {
 	return &Repository{
 		dbUser:     mongoClient.Database("research_apm").Collection("user"),
 		dbMessage:  dbMessage,
 		dbClientDO: dbClientDO,
 		dbProfil:   dbProfil,
 		dbRedis:    dbRedis,
+
+		clientDOCache: clientDOCache,
 	}
 }
 
@@ -42,8 +46,6 @@ func isTrue() bool {
 
 // get user
 func (repo *Repository) GetUser(ctx context.Context) ([]entity.User, error) {
-	ctx, span := tracer.StartSpan(ctx, "repository.GetUser")
-	defer span.End()
 	if !isTrue() {
 		time.Sleep(10 * time.Millisecond)
 		err := fmt.Errorf("dummy error get user")
@@ -70,8 +72,6 @@ func (repo *Repository) GetUser(ctx context.Context) ([]entity.User, error) {
 
 // create user
 func (repo *Repository) CreateUser(ctx context.Context, data entity.User) error {
-	ctx, span := tracer.StartSpan(ctx, "repository.CreateUser")
-	defer span.End()
 	if !isTrue() {
 		time.Sleep(10 * time.Millisecond)
 		err := fmt.Errorf("dummy error create user")
@@ -85,14 +85,12 @@ func (repo *Repository) CreateUser(ctx context.Context, data entity.User) error
 
 // get message
 func (repo *Repository) GetMessage(ctx context.Context) ([]entity.Message, error) {
-	ctx, span := tracer.StartSpan(ctx, "repository.GetMessage")
-	defer span.End()
 	if !isTrue() {
 		time.Sleep(10 * time.Millisecond)
 		err := fmt.Errorf("dummy error get message")
 		return nil, err
 	}
-	rows, err := repo.dbMessage.WithContext(ctx).
+	rows, err := gormx.FromContext(ctx, repo.dbMessage).
 		Model(&model.Message{}).
 		Limit(200).
 		Rows()
@@ -115,30 +113,17 @@ func (repo *Repository) GetMessage(ctx context.Context) ([]entity.Message, error
 
 // get client do
 func (repo *Repository) GetClientDO(ctx context.Context) ([]entity.ClientDo, error) {
-	ctx, span := tracer.StartSpan(ctx, "repository.GetClientDO")
-	defer span.End()
 	// if !isTrue() {
 	// 	time.Sleep(10 * time.Millisecond)
 	// 	err := errors.NewRetryable(fmt.Errorf("dummy error get client do"))
 	// 	tracer.CaptureError(ctx, err)
 	// 	return nil, err
 	// }
-	vals, err := repo.dbRedis.LRange(ctx, "research_apm.client_do", 0, -1).Result()
-	if err != nil {
-		return nil, err
-	}
-	result := make([]entity.ClientDo, 0)
-	if len(vals) > 0 {
-		for _, v := range vals {
-			var p entity.ClientDo
-			if err := json.Unmarshal([]byte(v), &p); err != nil {
-				return nil, err
-			}
-			result = append(result, p)
-		}
-		return result, nil
-	}
-	rows, err := repo.dbClientDO.WithContext(ctx).
+	return repo.clientDOCache.GetOrLoad(ctx, "client_do", 10*time.Second, repo.loadClientDO)
+}
+
+func (repo *Repository) loadClientDO(ctx context.Context) ([]entity.ClientDo, error) {
+	rows, err := gormx.FromContext(ctx, repo.dbClientDO).
 		Model(&model.ClientDo{}).
 		Limit(200).
 		Rows()
@@ -146,40 +131,26 @@ func (repo *Repository) GetClientDO(ctx context.Context) ([]entity.ClientDo, err
 		return nil, err
 	}
 	defer rows.Close()
-	listCache := make([]string, 0)
+	result := make([]entity.ClientDo, 0)
 
 	for rows.Next() {
 		var msg model.ClientDo
 		if err := repo.dbClientDO.ScanRows(rows, &msg); err != nil {
 			return nil, err
 		}
-		dd := msg.ToEntity()
-		result = append(result, dd)
-		if v, err := json.Marshal(dd); err == nil {
-			listCache = append(listCache, string(v))
-		}
-	}
-	if len(listCache) > 0 {
-		if err := repo.dbRedis.RPush(ctx, "research_apm.client_do", listCache).Err(); err != nil {
-			return nil, err
-		}
-		if err := repo.dbRedis.Expire(ctx, "research_apm.client_do", 10*time.Second).Err(); err != nil {
-			return nil, err
-		}
+		result = append(result, msg.ToEntity())
 	}
 	return result, nil
 }
 
 // get profil
 func (repo *Repository) GetProfil(ctx context.Context) ([]entity.Profil, error) {
-	ctx, span := tracer.StartSpan(ctx, "repository.GetProfil")
-	defer span.End()
 	if !isTrue() {
 		time.Sleep(10 * time.Millisecond)
 		err := fmt.Errorf("dummy error get profil")
 		return nil, err
 	}
-	rows, err := repo.dbProfil.WithContext(ctx).
+	rows, err := gormx.FromContext(ctx, repo.dbProfil).
 		Model(&model.Profil{}).
 		Limit(200).
 		Rows()
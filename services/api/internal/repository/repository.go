@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"research-apm/pkg/cache"
 	"research-apm/services/api/internal/entity"
 	"research-apm/services/api/internal/repository/internal/repository"
 
@@ -24,7 +25,11 @@ type Repository interface {
 	GetProfil(ctx context.Context) ([]entity.Profil, error)
 }
 
-func NewRepository(mongoClient *mongo.Client, dbMessage *gorm.DB, dbClientDo *gorm.DB, dbProfil *gorm.DB, dbRedis *redis.Client) Repository {
+// NewRepository creates the repository. clientDOCache caches the client_do
+// rows; its owner closes it.
+func NewRepository(mongoClient *mongo.Client, dbMessage *gorm.DB, dbClientDo *gorm.DB, dbProfil *gorm.DB, dbRedis redis.UniversalClient, clientDOCache cache.Cache[[]entity.ClientDo]) Repository {
 
-	return repository.NewRepository(mongoClient, dbMessage, dbClientDo, dbProfil, dbRedis)
+	return newTracedRepository(
+		newResilientRepository(repository.NewRepository(mongoClient, dbMessage, dbClientDo, dbProfil, dbRedis, clientDOCache)),
+	)
 }
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"research-apm/pkg/breaker"
+	"research-apm/pkg/bulkhead"
+	"research-apm/services/api/internal/entity"
+)
+
+// dependency groups the resilience primitives guarding a single backing store.
+type dependency struct {
+	bulkhead *bulkhead.Bulkhead
+	breaker  *breaker.Breaker
+}
+
+func newDependency(name string, maxConcurrent int) dependency {
+	return dependency{
+		bulkhead: bulkhead.New(bulkhead.Config{
+			Name:          name,
+			MaxConcurrent: maxConcurrent,
+			MaxQueue:      maxConcurrent * 2,
+		}),
+		breaker: breaker.New(breaker.Config{Name: name}),
+	}
+}
+
+// call runs fn through the bulkhead first, so a slow dependency is capped
+// before it is counted by the breaker, then through the circuit breaker.
+func call[T any](ctx context.Context, dep dependency, fn func(ctx context.Context) (T, error)) (T, error) {
+	return bulkhead.DoValue(ctx, dep.bulkhead, func(ctx context.Context) (T, error) {
+		return breaker.DoValue(ctx, dep.breaker, fn)
+	})
+}
+
+// resilientRepository wraps a Repository with one bulkhead and circuit breaker
+// per dependency, so a database that is slow or down fails fast instead of
+// waiting for a driver timeout and starving the other dependencies.
+type resilientRepository struct {
+	next     Repository
+	user     dependency // mongodb
+	message  dependency // postgres
+	clientDO dependency // redis + sqlserver
+	profil   dependency // mysql
+}
+
+func newResilientRepository(next Repository) Repository {
+	return &resilientRepository{
+		next:     next,
+		user:     newDependency("mongodb", 5),
+		message:  newDependency("postgres", 10),
+		clientDO: newDependency("sqlserver", 10),
+		profil:   newDependency("mysql", 10),
+	}
+}
+
+// get user
+func (repo *resilientRepository) GetUser(ctx context.Context) ([]entity.User, error) {
+	return call(ctx, repo.user, repo.next.GetUser)
+}
+
+// create user
+func (repo *resilientRepository) CreateUser(ctx context.Context, data entity.User) error {
+	_, err := call(ctx, repo.user, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, repo.next.CreateUser(ctx, data)
+	})
+	return err
+}
+
+// get message
+func (repo *resilientRepository) GetMessage(ctx context.Context) ([]entity.Message, error) {
+	return call(ctx, repo.message, repo.next.GetMessage)
+}
+
+// get client do
+func (repo *resilientRepository) GetClientDO(ctx context.Context) ([]entity.ClientDo, error) {
+	return call(ctx, repo.clientDO, repo.next.GetClientDO)
+}
+
+// get profil
+func (repo *resilientRepository) GetProfil(ctx context.Context) ([]entity.Profil, error) {
+	return call(ctx, repo.profil, repo.next.GetProfil)
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"research-apm/pkg/tracer"
+	"research-apm/services/api/internal/entity"
+)
+
+// tracedRepository wraps a Repository so that every call gets a span
+// named after the method. Errors only set the span outcome; the error event
+// is captured once, at the request or job edge that handles it.
+type tracedRepository struct {
+	next Repository
+}
+
+func newTracedRepository(next Repository) Repository {
+	return &tracedRepository{next: next}
+}
+
+// get user
+func (repo *tracedRepository) GetUser(ctx context.Context) ([]entity.User, error) {
+	return tracer.DoValue(ctx, "repository.GetUser", repo.next.GetUser)
+}
+
+// create user
+func (repo *tracedRepository) CreateUser(ctx context.Context, data entity.User) error {
+	return tracer.Do(ctx, "repository.CreateUser", func(ctx context.Context) error {
+		return repo.next.CreateUser(ctx, data)
+	})
+}
+
+// get message
+func (repo *tracedRepository) GetMessage(ctx context.Context) ([]entity.Message, error) {
+	return tracer.DoValue(ctx, "repository.GetMessage", repo.next.GetMessage)
+}
+
+// get client do
+func (repo *tracedRepository) GetClientDO(ctx context.Context) ([]entity.ClientDo, error) {
+	return tracer.DoValue(ctx, "repository.GetClientDO", repo.next.GetClientDO)
+}
+
+// get profil
+func (repo *tracedRepository) GetProfil(ctx context.Context) ([]entity.Profil, error) {
+	return tracer.DoValue(ctx, "repository.GetProfil", repo.next.GetProfil)
+}
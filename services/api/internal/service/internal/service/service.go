@@ -2,9 +2,10 @@ package service
 
 import (
 	"context"
+	"research-apm/pkg/breaker"
 	"research-apm/pkg/errors"
 	"research-apm/pkg/errors/codes"
-	"research-apm/pkg/tracer"
+	"research-apm/pkg/retry"
 	"research-apm/services/api/internal/entity"
 	"research-apm/services/api/internal/repository"
 	"time"
@@ -13,18 +14,23 @@ import (
 )
 
 type Service struct {
-	repo repository.Repository
+	repo  repository.Repository
+	retry retry.Policy
 }
 
 func NewService(repo repository.Repository) *Service {
-	return &Service{repo: repo}
+	policy := retry.DefaultPolicy("repository")
+	policy.Budget = retry.NewBudget(10, 0.1)
+	// An open breaker rejects the retries too, so fail fast.
+	policy.Classifier = func(err error) bool {
+		return errors.IsRetryable(err) && !breaker.IsOpen(err)
+	}
+	return &Service{repo: repo, retry: policy}
 }
 
 // get user
 func (service *Service) GetUser(ctx context.Context) ([]entity.User, error) {
-	ctx, span := tracer.StartSpan(ctx, "service.GetUser")
-	defer span.End()
-	result, err := service.repo.GetUser(ctx)
+	result, err := retry.DoValue(ctx, service.retry, service.repo.GetUser)
 	if err != nil {
 		return nil, errors.Wrap(codes.Internal, "gagal mencari data user", err)
 	}
@@ -33,11 +39,12 @@ func (service *Service) GetUser(ctx context.Context) ([]entity.User, error) {
 
 // create user
 func (service *Service) CreateUser(ctx context.Context, data entity.User) (string, error) {
-	ctx, span := tracer.StartSpan(ctx, "service.CreateUser")
-	defer span.End()
 	data.ID = ulid.Make().String()
 	data.CreatedAt = time.Now()
 	data.UpdatedAt = time.Now()
+	// Not retried: the insert is not idempotent. An attempt that failed
+	// with an ambiguous error may have been committed, and its retry would
+	// then answer 409 Conflict for a user that was created.
 	if err := service.repo.CreateUser(ctx, data); err != nil {
 		return "", errors.Wrap(codes.Internal, "gagal membuat user", err)
 	}
@@ -47,9 +54,7 @@ func (service *Service) CreateUser(ctx context.Context, data entity.User) (strin
 
 // get message
 func (service *Service) GetMessage(ctx context.Context) ([]entity.Message, error) {
-	ctx, span := tracer.StartSpan(ctx, "service.GetMessage")
-	defer span.End()
-	result, err := service.repo.GetMessage(ctx)
+	result, err := retry.DoValue(ctx, service.retry, service.repo.GetMessage)
 	if err != nil {
 		return nil, errors.Wrap(codes.Internal, "gagal mencari data message", err)
 	}
@@ -58,11 +63,8 @@ func (service *Service) GetMessage(ctx context.Context) ([]entity.Message, error
 
 // get client do
 func (service *Service) GetClientDO(ctx context.Context) ([]entity.ClientDo, error) {
-	ctx, span := tracer.StartSpan(ctx, "service.GetClientDO")
-	defer span.End()
-	result, err := service.repo.GetClientDO(ctx)
+	result, err := retry.DoValue(ctx, service.retry, service.repo.GetClientDO)
 	if err != nil {
-		tracer.CaptureError(ctx, err)
 		return nil, errors.Wrap(codes.Internal, "gagal mencari data client do", err)
 	}
 	return result, nil
@@ -70,9 +72,7 @@ func (service *Service) GetClientDO(ctx context.Context) ([]entity.ClientDo, err
 
 // get profil
 func (service *Service) GetProfil(ctx context.Context) ([]entity.Profil, error) {
-	ctx, span := tracer.StartSpan(ctx, "service.GetProfil")
-	defer span.End()
-	result, err := service.repo.GetProfil(ctx)
+	result, err := retry.DoValue(ctx, service.retry, service.repo.GetProfil)
 	if err != nil {
 		return nil, errors.Wrap(codes.Internal, "gagal mencari data profil", err)
 	}
@@ -25,5 +25,5 @@ type Service interface {
 }
 
 func NewService(repo repository.Repository) Service {
-	return service.NewService(repo)
+	return newTracedService(service.NewService(repo))
 }
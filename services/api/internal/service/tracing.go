@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"research-apm/pkg/tracer"
+	"research-apm/services/api/internal/entity"
+)
+
+// tracedService wraps a Service so that every call gets a span
+// named after the method. Errors only set the span outcome; the error event
+// is captured once, at the request or job edge that handles it.
+type tracedService struct {
+	next Service
+}
+
+func newTracedService(next Service) Service {
+	return &tracedService{next: next}
+}
+
+// get user
+func (s *tracedService) GetUser(ctx context.Context) ([]entity.User, error) {
+	return tracer.DoValue(ctx, "service.GetUser", s.next.GetUser)
+}
+
+// create user
+func (s *tracedService) CreateUser(ctx context.Context, data entity.User) (string, error) {
+	return tracer.DoValue(ctx, "service.CreateUser", func(ctx context.Context) (string, error) {
+		return s.next.CreateUser(ctx, data)
+	})
+}
+
+// get message
+func (s *tracedService) GetMessage(ctx context.Context) ([]entity.Message, error) {
+	return tracer.DoValue(ctx, "service.GetMessage", s.next.GetMessage)
+}
+
+// get client do
+func (s *tracedService) GetClientDO(ctx context.Context) ([]entity.ClientDo, error) {
+	return tracer.DoValue(ctx, "service.GetClientDO", s.next.GetClientDO)
+}
+
+// get profil
+func (s *tracedService) GetProfil(ctx context.Context) ([]entity.Profil, error) {
+	return tracer.DoValue(ctx, "service.GetProfil", s.next.GetProfil)
+}